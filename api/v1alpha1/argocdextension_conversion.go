@@ -0,0 +1,153 @@
+package v1alpha1
+
+import (
+	"encoding/json"
+	"reflect"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+const (
+	// hubStateAnnotation preserves v1beta1 spec fields that can't be represented in v1alpha1 so the object
+	// can be converted back without losing data.
+	hubStateAnnotation = "argocd-extensions.argoproj.io/v1beta1-state"
+
+	// reasonConverted is the condition reason used for conditions written using v1alpha1 API
+	reasonConverted = "Converted"
+)
+
+// hubState holds the v1beta1 spec fields without v1alpha1 equivalent. The status is not preserved since the API
+// server ignores status changes made with the main resource.
+type hubState struct {
+	Spec    v1beta1.ArgoCDExtensionSpec `json:"spec"`
+	Sources []hubSource                 `json:"sources,omitempty"`
+}
+
+// hubSource holds the source fields without v1alpha1 equivalent. Url identifies the source they belong to.
+type hubSource struct {
+	Url      string `json:"url,omitempty"`
+	Path     string `json:"path,omitempty"`
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// ConvertTo converts this ArgoCDExtension to the hub version (v1beta1).
+func (src *ArgoCDExtension) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.ArgoCDExtension)
+
+	var state hubState
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	if data, ok := dst.Annotations[hubStateAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &state); err != nil {
+			return err
+		}
+		delete(dst.Annotations, hubStateAnnotation)
+		if len(dst.Annotations) == 0 {
+			dst.Annotations = nil
+		}
+	}
+
+	dst.Spec = state.Spec
+	dst.Spec.Sources = nil
+	for i, s := range src.Spec.Sources {
+		// restore fields that only exist in v1beta1 if the source has not been replaced
+		var prev hubSource
+		if i < len(state.Sources) {
+			prev = state.Sources[i]
+		}
+		var source v1beta1.ExtensionSource
+		switch {
+		case s.Git != nil:
+			source.Type = v1beta1.SourceTypeGit
+			source.Git = &v1beta1.GitSource{Url: s.Git.Url, Revision: s.Git.Revision}
+			if prev.Url == s.Git.Url {
+				source.Git.Path = prev.Path
+			}
+		case s.Web != nil:
+			source.Type = v1beta1.SourceTypeWeb
+			source.Web = &v1beta1.WebSource{Url: s.Web.Url}
+			if prev.Url == s.Web.Url {
+				source.Web.Checksum = prev.Checksum
+			}
+		}
+		dst.Spec.Sources = append(dst.Spec.Sources, source)
+	}
+
+	dst.Status = v1beta1.ArgoCDExtensionStatus{}
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, metav1.Condition{
+			Type:               string(c.Type),
+			Status:             c.Status,
+			Message:            c.Message,
+			Reason:             reasonConverted,
+			LastTransitionTime: src.CreationTimestamp,
+		})
+	}
+	return nil
+}
+
+// ConvertFrom converts from the hub version (v1beta1) to this version.
+func (dst *ArgoCDExtension) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.ArgoCDExtension)
+
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	var state hubState
+	src.Spec.DeepCopyInto(&state.Spec)
+	state.Spec.Sources = nil
+	for i, s := range src.Spec.Sources {
+		var source hubSource
+		switch {
+		case s.Git != nil && s.Git.Path != "":
+			source = hubSource{Url: s.Git.Url, Path: s.Git.Path}
+		case s.Web != nil && s.Web.Checksum != "":
+			source = hubSource{Url: s.Web.Url, Checksum: s.Web.Checksum}
+		default:
+			continue
+		}
+		// sources are matched by index, so sources without v1beta1 fields are kept before the ones with
+		for len(state.Sources) < i {
+			state.Sources = append(state.Sources, hubSource{})
+		}
+		state.Sources = append(state.Sources, source)
+	}
+	if !reflect.DeepEqual(state, hubState{}) {
+		data, err := json.Marshal(state)
+		if err != nil {
+			return err
+		}
+		if dst.Annotations == nil {
+			dst.Annotations = map[string]string{}
+		}
+		dst.Annotations[hubStateAnnotation] = string(data)
+	} else {
+		delete(dst.Annotations, hubStateAnnotation)
+	}
+
+	dst.Spec.Sources = nil
+	for _, s := range src.Spec.Sources {
+		var source ExtensionSource
+		switch s.Type {
+		case v1beta1.SourceTypeGit:
+			if s.Git != nil {
+				source.Git = &GitSource{Url: s.Git.Url, Revision: s.Git.Revision}
+			}
+		case v1beta1.SourceTypeWeb:
+			if s.Web != nil {
+				source.Web = &WebSource{Url: s.Web.Url}
+			}
+		}
+		dst.Spec.Sources = append(dst.Spec.Sources, source)
+	}
+
+	dst.Status.Conditions = nil
+	for _, c := range src.Status.Conditions {
+		dst.Status.Conditions = append(dst.Status.Conditions, ArgoCDExtensionCondition{
+			Type:    ArgoCDExtensionConditionType(c.Type),
+			Status:  c.Status,
+			Message: c.Message,
+		})
+	}
+	return nil
+}
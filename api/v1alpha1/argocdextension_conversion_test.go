@@ -0,0 +1,103 @@
+package v1alpha1
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func TestConversionRoundTrip(t *testing.T) {
+	gitSource := func(url, path string) v1beta1.ExtensionSource {
+		return v1beta1.ExtensionSource{Type: v1beta1.SourceTypeGit, Git: &v1beta1.GitSource{Url: url, Revision: "main", Path: path}}
+	}
+	webSource := func(url, checksum string) v1beta1.ExtensionSource {
+		return v1beta1.ExtensionSource{Type: v1beta1.SourceTypeWeb, Web: &v1beta1.WebSource{Url: url, Checksum: checksum}}
+	}
+	for _, tc := range []struct {
+		name           string
+		spec           v1beta1.ArgoCDExtensionSpec
+		annotations    map[string]string
+		wantAnnotation bool
+	}{
+		{name: "v1alpha1 fields only", spec: v1beta1.ArgoCDExtensionSpec{
+			Sources: []v1beta1.ExtensionSource{gitSource("https://git/a.git", ""), webSource("https://web/b.tar", "")},
+		}},
+		{name: "other annotations", annotations: map[string]string{"team": "platform"}, spec: v1beta1.ArgoCDExtensionSpec{
+			Sources: []v1beta1.ExtensionSource{gitSource("https://git/a.git", "")},
+		}},
+		{name: "source fields", wantAnnotation: true, spec: v1beta1.ArgoCDExtensionSpec{
+			Sources: []v1beta1.ExtensionSource{
+				gitSource("https://git/a.git", ""),
+				webSource("https://web/b.tar", "sha256:abc"),
+				gitSource("https://git/c.git", "extensions/c"),
+			},
+		}},
+		{name: "v1beta1 spec", wantAnnotation: true, spec: v1beta1.ArgoCDExtensionSpec{
+			Catalog:        &v1beta1.CatalogReference{Name: "catalog", Extension: "metrics", Version: "1.2.0"},
+			Destination:    v1beta1.ExtensionDestination{Path: "metrics"},
+			ApplyManifests: true,
+			DependsOn:      []v1beta1.ExtensionDependency{{Name: "base", Version: ">= 1.0"}},
+			Config:         &v1beta1.ExtensionConfig{Values: &apiextensionsv1.JSON{Raw: []byte(`{"url":"https://grafana"}`)}},
+			Target:         &v1beta1.ExtensionTarget{InstanceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			src := &v1beta1.ArgoCDExtension{
+				ObjectMeta: metav1.ObjectMeta{Name: "metrics", Namespace: "argocd", Annotations: tc.annotations},
+				Spec:       tc.spec,
+				Status: v1beta1.ArgoCDExtensionStatus{
+					Conditions: []metav1.Condition{{Type: v1beta1.ConditionReady, Status: metav1.ConditionTrue, Reason: "Processed", Message: "ok"}},
+					Warnings:   []string{"not preserved"},
+				},
+			}
+			var alpha ArgoCDExtension
+			if err := alpha.ConvertFrom(src); err != nil {
+				t.Fatal(err)
+			}
+			data, ok := alpha.Annotations[hubStateAnnotation]
+			if ok != tc.wantAnnotation {
+				t.Fatalf("state annotation present = %v, want %v", ok, tc.wantAnnotation)
+			}
+			if strings.Contains(data, "status") || strings.Contains(data, "revision") {
+				t.Errorf("state annotation holds fields with v1alpha1 equivalent: %s", data)
+			}
+
+			var dst v1beta1.ArgoCDExtension
+			if err := alpha.ConvertTo(&dst); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(dst.Spec, src.Spec) {
+				t.Errorf("spec = %+v, want %+v", dst.Spec, src.Spec)
+			}
+			if !reflect.DeepEqual(dst.ObjectMeta, src.ObjectMeta) {
+				t.Errorf("metadata = %+v, want %+v", dst.ObjectMeta, src.ObjectMeta)
+			}
+			if c := dst.Status.Conditions; len(c) != 1 || c[0].Type != v1beta1.ConditionReady || c[0].Status != metav1.ConditionTrue || c[0].Message != "ok" {
+				t.Errorf("conditions = %+v", c)
+			}
+		})
+	}
+}
+
+func TestConversionReplacedSource(t *testing.T) {
+	src := &v1beta1.ArgoCDExtension{Spec: v1beta1.ArgoCDExtensionSpec{Sources: []v1beta1.ExtensionSource{{
+		Type: v1beta1.SourceTypeGit, Git: &v1beta1.GitSource{Url: "https://git/a.git", Path: "extensions/a"},
+	}}}}
+	var alpha ArgoCDExtension
+	if err := alpha.ConvertFrom(src); err != nil {
+		t.Fatal(err)
+	}
+	alpha.Spec.Sources[0].Git.Url = "https://git/b.git"
+	var dst v1beta1.ArgoCDExtension
+	if err := alpha.ConvertTo(&dst); err != nil {
+		t.Fatal(err)
+	}
+	if path := dst.Spec.Sources[0].Git.Path; path != "" {
+		t.Errorf("path of the replaced source is %q, want empty", path)
+	}
+}
@@ -0,0 +1,293 @@
+package v1beta1
+
+import (
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ArgoCDExtensionSpec defines the desired state of ArgoCDExtension
+type ArgoCDExtensionSpec struct {
+	// Sources specifies where the extension should come from. Either sources or catalog must be specified.
+	Sources []ExtensionSource `json:"sources,omitempty"`
+	// Catalog installs the extension sources listed in an ArgoCDExtensionCatalog
+	Catalog *CatalogReference `json:"catalog,omitempty"`
+	// Destination specifies where the extension files should be installed
+	Destination ExtensionDestination `json:"destination,omitempty"`
+	// RBAC configures merging of the RBAC policy declared by the extension into argocd-rbac-cm
+	RBAC *ExtensionRBAC `json:"rbac,omitempty"`
+	// ApplyManifests enables server-side apply of the Kubernetes objects shipped in the manifests directory of the
+	// extension. Applied objects that are removed from the extension are pruned.
+	ApplyManifests bool `json:"applyManifests,omitempty"`
+	// DependsOn lists the extensions in the same namespace that must be Ready before this extension is installed
+	DependsOn []ExtensionDependency `json:"dependsOn,omitempty"`
+	// Config holds environment-specific values rendered into the installed extension files
+	Config *ExtensionConfig `json:"config,omitempty"`
+	// Target restricts the Argo CD instances that install the extension. All instances install it if empty.
+	Target *ExtensionTarget `json:"target,omitempty"`
+}
+
+// ExtensionTarget selects the Argo CD instances that install the extension
+type ExtensionTarget struct {
+	// InstanceSelector selects the instances by the labels the controller of every instance is started with
+	// (--instance-labels)
+	InstanceSelector *metav1.LabelSelector `json:"instanceSelector,omitempty"`
+}
+
+// ExtensionConfig holds the configuration values of an extension. Values from the ConfigMap are overridden by the
+// inline values with the same name.
+type ExtensionConfig struct {
+	// Values holds free-form configuration values
+	// +kubebuilder:pruning:PreserveUnknownFields
+	// +kubebuilder:validation:Schemaless
+	// +kubebuilder:validation:Type=object
+	Values *apiextensionsv1.JSON `json:"values,omitempty"`
+	// ConfigMapRef takes configuration values from a ConfigMap in the same namespace
+	ConfigMapRef *ConfigMapValuesReference `json:"configMapRef,omitempty"`
+}
+
+// ConfigMapValuesReference selects the configuration values stored in a ConfigMap
+type ConfigMapValuesReference struct {
+	// Name is the ConfigMap name
+	Name string `json:"name"`
+	// Key is a ConfigMap key holding the values as a YAML or JSON object. If empty, every ConfigMap key is a
+	// string value.
+	Key string `json:"key,omitempty"`
+}
+
+// ExtensionDependency references an extension this extension depends on
+type ExtensionDependency struct {
+	// Name is the name of the ArgoCDExtension
+	Name string `json:"name"`
+	// Version is a constraint (e.g. ">= 1.2, < 2.0") the version declared in the dependency manifest must satisfy
+	Version string `json:"version,omitempty"`
+}
+
+const (
+	// ConditionReady indicates that all extension sources have been installed
+	ConditionReady = "Ready"
+	// ConditionRBACConfigured indicates that Argo CD RBAC grants the permissions the extension requires
+	ConditionRBACConfigured = "RBACConfigured"
+	// ConditionManifestsHealthy indicates that all Kubernetes objects applied for the extension are healthy
+	ConditionManifestsHealthy = "ManifestsHealthy"
+)
+
+const (
+	// ReasonProcessed is used when all extension sources have been successfully processed
+	ReasonProcessed = "Processed"
+	// ReasonProcessingFailed is used when at least one extension source could not be processed
+	ReasonProcessingFailed = "ProcessingFailed"
+	// ReasonIncompatible is used when the extension does not support the running Argo CD version
+	ReasonIncompatible = "Incompatible"
+	// ReasonPermissionsGranted is used when Argo CD RBAC grants all permissions required by the extension
+	ReasonPermissionsGranted = "PermissionsGranted"
+	// ReasonPermissionsMissing is used when Argo CD RBAC lacks permissions required by the extension
+	ReasonPermissionsMissing = "PermissionsMissing"
+	// ReasonDependenciesNotReady is used when at least one dependency is missing, not Ready or has an unsupported version
+	ReasonDependenciesNotReady = "DependenciesNotReady"
+	// ReasonDependencyCycle is used when the extension transitively depends on itself
+	ReasonDependencyCycle = "DependencyCycle"
+	// ReasonHealthy is used when all applied objects are healthy
+	ReasonHealthy = "Healthy"
+	// ReasonProgressing is used when at least one applied object has not reached the desired state yet
+	ReasonProgressing = "Progressing"
+	// ReasonDegraded is used when at least one applied object has failed
+	ReasonDegraded = "Degraded"
+	// ReasonArtifactPending is used when the coordinator has not published the extension artifact yet
+	ReasonArtifactPending = "ArtifactPending"
+	// ReasonReplicasNotReady is used when at least one live replica has not installed the revisions of the current spec
+	ReasonReplicasNotReady = "ReplicasNotReady"
+)
+
+// ArgoCDExtensionStatus defines the observed state of ArgoCDExtension
+type ArgoCDExtensionStatus struct {
+	// Conditions is a list of conditions describing the extension state
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Extension holds the metadata declared in the installed extension manifest
+	Extension *ExtensionMetadata `json:"extension,omitempty"`
+	// Warnings lists issues found in the installed extension files that did not prevent the installation
+	Warnings []string `json:"warnings,omitempty"`
+	// AvailableVersion is the latest compatible version of the catalog channel if it is newer than the pinned version
+	AvailableVersion string `json:"availableVersion,omitempty"`
+	// Resources lists the Kubernetes objects applied from the extension manifests
+	Resources []ResourceStatus `json:"resources,omitempty"`
+	// Replicas holds the state of the extension in every replica of the Argo CD server. The Ready condition is only
+	// true once all live replicas installed the same revisions of the current spec.
+	// +listType=map
+	// +listMapKey=pod
+	Replicas []ReplicaStatus `json:"replicas,omitempty"`
+	// Artifact references the archive of the extension files published by the coordinator and installed by the agents
+	Artifact *ArtifactStatus `json:"artifact,omitempty"`
+}
+
+// ArtifactStatus references the content-addressed archive of the extension files published by the coordinator
+type ArtifactStatus struct {
+	// Digest is the sha256 digest of the archive, e.g. sha256:2c26b46b...
+	Digest string `json:"digest"`
+	// Revisions lists the resolved revisions of the extension sources packaged in the archive
+	Revisions []string `json:"revisions,omitempty"`
+	// ConfigMaps lists the names of the immutable ConfigMaps holding the archive chunks in order
+	ConfigMaps []string `json:"configMaps"`
+	// Size is the archive size in bytes
+	Size int64 `json:"size,omitempty"`
+}
+
+// ReplicaStatus is the state of the extension in a single replica of the Argo CD server
+type ReplicaStatus struct {
+	// Pod is the name of the replica pod
+	Pod string `json:"pod"`
+	// Ready is True if the replica installed the extension
+	Ready metav1.ConditionStatus `json:"ready"`
+	// Reason is the reason of the Ready condition of the replica
+	Reason string `json:"reason,omitempty"`
+	// Message describes the Ready condition of the replica
+	Message string `json:"message,omitempty"`
+	// Revision lists the installed revisions of the extension sources
+	Revision string `json:"revision,omitempty"`
+	// ObservedGeneration is the generation of the spec processed by the replica
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// LastTransitionTime is the last time the state of the replica changed
+	LastTransitionTime metav1.Time `json:"lastTransitionTime,omitempty"`
+}
+
+// HealthStatus is the health of an applied Kubernetes object
+// +kubebuilder:validation:Enum=Healthy;Progressing;Degraded
+type HealthStatus string
+
+const (
+	HealthStatusHealthy     HealthStatus = "Healthy"
+	HealthStatusProgressing HealthStatus = "Progressing"
+	HealthStatusDegraded    HealthStatus = "Degraded"
+)
+
+// ResourceStatus identifies a Kubernetes object applied from the extension manifests and holds its health
+type ResourceStatus struct {
+	// Group is the API group of the object
+	Group string `json:"group,omitempty"`
+	// Version is the API version of the object
+	Version string `json:"version"`
+	// Kind is the kind of the object
+	Kind string `json:"kind"`
+	// Namespace is the namespace of the object, empty for cluster-scoped objects
+	Namespace string `json:"namespace,omitempty"`
+	// Name is the name of the object
+	Name string `json:"name"`
+	// Health is the health of the object
+	Health HealthStatus `json:"health,omitempty"`
+	// Message describes the health of the object
+	Message string `json:"message,omitempty"`
+}
+
+// ExtensionMetadata holds the metadata declared in the extension manifest
+type ExtensionMetadata struct {
+	// Name is the extension name
+	Name string `json:"name"`
+	// Version is the extension version
+	Version string `json:"version"`
+	// Description is a human-readable description of the extension
+	Description string `json:"description,omitempty"`
+	// Type describes what the extension adds to Argo CD
+	Type string `json:"type"`
+	// ArgoCDVersion is the Argo CD version constraint declared by the extension
+	ArgoCDVersion string `json:"argocdVersion,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+
+// ArgoCDExtension is the Schema for the argocdextensions API
+type ArgoCDExtension struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ArgoCDExtensionSpec   `json:"spec,omitempty"`
+	Status ArgoCDExtensionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ArgoCDExtensionList contains a list of ArgoCDExtension
+type ArgoCDExtensionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ArgoCDExtension `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ArgoCDExtension{}, &ArgoCDExtensionList{})
+}
+
+// SourceType is the type of the extension source
+// +kubebuilder:validation:Enum=Git;Web
+type SourceType string
+
+const (
+	SourceTypeGit SourceType = "Git"
+	SourceTypeWeb SourceType = "Web"
+)
+
+// ExtensionSource specifies where the extension should be sourced from
+type ExtensionSource struct {
+	// Type specifies which of the source fields is used
+	Type SourceType `json:"type"`
+	// Git is specified if the extension should be sourced from a git repository
+	Git *GitSource `json:"git,omitempty"`
+	// Web is specified if the extension should be sourced from a web file
+	Web *WebSource `json:"web,omitempty"`
+}
+
+// GitSource specifies a repo that holds an extension
+type GitSource struct {
+	// URL specifies the Git repository URL to fetch
+	Url string `json:"url"`
+	// Revision specifies the revision of the Repository to fetch
+	Revision string `json:"revision,omitempty"`
+	// Path specifies the repository directory that holds the extension, defaults to the repository root
+	Path string `json:"path,omitempty"`
+}
+
+// WebSource specifies a remote file that holds an extension
+type WebSource struct {
+	// URL specifies the remote file URL
+	Url string `json:"url"`
+	// Checksum is the expected checksum of the remote file as <type>:<value>, e.g. sha256:<hex>
+	Checksum string `json:"checksum,omitempty"`
+}
+
+// CatalogReference selects an extension version listed in an ArgoCDExtensionCatalog
+type CatalogReference struct {
+	// Name is the name of the ArgoCDExtensionCatalog in the same namespace
+	Name string `json:"name"`
+	// Extension is the name of the extension in the catalog
+	Extension string `json:"extension"`
+	// Channel restricts the candidate versions to the ones published in the channel, defaults to stable
+	Channel string `json:"channel,omitempty"`
+	// Version pins the installed version. The latest compatible version of the channel is installed if empty.
+	Version string `json:"version,omitempty"`
+}
+
+// ExtensionDestination specifies where the extension should be installed
+type ExtensionDestination struct {
+	// Path specifies the directory, relative to the extensions directory, that receives the extension files
+	Path string `json:"path,omitempty"`
+}
+
+// ExtensionRBAC configures how the RBAC policy declared by the extension is merged into argocd-rbac-cm
+type ExtensionRBAC struct {
+	// Enabled merges the policy declared in the extension manifest into a managed block of the argocd-rbac-cm policy.csv
+	Enabled bool `json:"enabled"`
+	// Bindings lists additional role bindings merged along with the extension policy
+	Bindings []RoleBinding `json:"bindings,omitempty"`
+}
+
+// RoleBinding assigns an Argo CD role to a user or group
+type RoleBinding struct {
+	// Subject is the user or group name. Commas, quotes and line breaks are not allowed, since the binding is written
+	// as a policy.csv line.
+	// +kubebuilder:validation:Pattern=`^[^,"\r\n]+$`
+	Subject string `json:"subject"`
+	// Role is the Argo CD role name, e.g. role:metrics
+	// +kubebuilder:validation:Pattern=`^[^,"\r\n]+$`
+	Role string `json:"role"`
+}
@@ -0,0 +1,81 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ArgoCDExtensionCatalogSpec defines the desired state of ArgoCDExtensionCatalog
+type ArgoCDExtensionCatalogSpec struct {
+	// Source specifies where the catalog index file comes from. The path of a Git source is the index file path
+	// relative to the repository root and defaults to index.yaml.
+	Source ExtensionSource `json:"source"`
+	// RefreshInterval is the delay between index updates, defaults to 10m
+	RefreshInterval *metav1.Duration `json:"refreshInterval,omitempty"`
+}
+
+// CatalogExtension is an extension listed in the catalog index
+type CatalogExtension struct {
+	// Name is the extension name
+	Name string `json:"name"`
+	// Description is a human-readable description of the extension
+	Description string `json:"description,omitempty"`
+	// Versions lists the published extension versions
+	Versions []CatalogVersion `json:"versions"`
+}
+
+// CatalogVersion is a published extension version
+type CatalogVersion struct {
+	// Version is the extension semantic version
+	Version string `json:"version"`
+	// Channels lists the channels the version is published in, e.g. stable or beta
+	Channels []string `json:"channels,omitempty"`
+	// ArgoCDVersion is a version constraint the running Argo CD must satisfy
+	ArgoCDVersion string `json:"argocdVersion,omitempty"`
+	// Sources specifies where the extension version comes from. Web sources should specify a checksum.
+	Sources []ExtensionSource `json:"sources"`
+}
+
+const (
+	// ReasonIndexUpdated is used when the catalog index has been successfully fetched
+	ReasonIndexUpdated = "IndexUpdated"
+	// ReasonIndexFailed is used when the catalog index could not be fetched or is invalid
+	ReasonIndexFailed = "IndexFailed"
+)
+
+// ArgoCDExtensionCatalogStatus defines the observed state of ArgoCDExtensionCatalog
+type ArgoCDExtensionCatalogStatus struct {
+	// Conditions is a list of conditions describing the catalog state
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Revision is the resolved revision of the index file
+	Revision string `json:"revision,omitempty"`
+	// LastUpdateTime is the time the index was last fetched
+	LastUpdateTime *metav1.Time `json:"lastUpdateTime,omitempty"`
+	// Extensions lists the extensions of the catalog index
+	Extensions []CatalogExtension `json:"extensions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ArgoCDExtensionCatalog lists approved extensions and their published versions
+type ArgoCDExtensionCatalog struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ArgoCDExtensionCatalogSpec   `json:"spec,omitempty"`
+	Status ArgoCDExtensionCatalogStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ArgoCDExtensionCatalogList contains a list of ArgoCDExtensionCatalog
+type ArgoCDExtensionCatalogList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ArgoCDExtensionCatalog `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ArgoCDExtensionCatalog{}, &ArgoCDExtensionCatalogList{})
+}
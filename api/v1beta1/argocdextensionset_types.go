@@ -0,0 +1,112 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ArgoCDExtensionSetSpec defines the desired state of ArgoCDExtensionSet
+type ArgoCDExtensionSetSpec struct {
+	// Generators produce the parameter sets the template is rendered with, one ArgoCDExtension per parameter set
+	Generators []ExtensionSetGenerator `json:"generators"`
+	// Template is the ArgoCDExtension template. String values may reference generator parameters as {{param}}.
+	Template ArgoCDExtensionTemplate `json:"template"`
+}
+
+// ExtensionSetGenerator produces parameter sets. Exactly one of the generator fields must be set.
+type ExtensionSetGenerator struct {
+	// List generates one parameter set per element
+	List *ListGenerator `json:"list,omitempty"`
+	// Git generates parameter sets from directories or files of a Git repository
+	Git *GitGenerator `json:"git,omitempty"`
+}
+
+// ListGenerator generates parameter sets from a literal list
+type ListGenerator struct {
+	// Elements lists the parameter sets
+	Elements []map[string]string `json:"elements"`
+}
+
+// GitGenerator generates parameter sets from a Git repository
+type GitGenerator struct {
+	// RepoURL is the Git repository URL
+	RepoURL string `json:"repoURL"`
+	// Revision is the Git revision to scan, defaults to HEAD
+	Revision string `json:"revision,omitempty"`
+	// Directories generates one parameter set per matching directory holding an extension manifest
+	Directories []GitDirectoryGeneratorItem `json:"directories,omitempty"`
+	// Files generates parameter sets from the entries of matching YAML or JSON files, such as extensions.yaml
+	Files []GitFileGeneratorItem `json:"files,omitempty"`
+}
+
+// GitDirectoryGeneratorItem matches repository directories
+type GitDirectoryGeneratorItem struct {
+	// Path is a glob pattern matching directories relative to the repository root
+	Path string `json:"path"`
+	// Exclude removes the matching directories from the result
+	Exclude bool `json:"exclude,omitempty"`
+}
+
+// GitFileGeneratorItem matches repository files
+type GitFileGeneratorItem struct {
+	// Path is a glob pattern matching files relative to the repository root
+	Path string `json:"path"`
+}
+
+// ArgoCDExtensionTemplate is the template of the ArgoCDExtension objects generated by an ArgoCDExtensionSet
+type ArgoCDExtensionTemplate struct {
+	// Metadata holds the metadata of the generated objects
+	Metadata ArgoCDExtensionTemplateMeta `json:"metadata"`
+	// Spec is the spec of the generated objects
+	Spec ArgoCDExtensionSpec `json:"spec"`
+}
+
+// ArgoCDExtensionTemplateMeta holds the templated metadata of a generated ArgoCDExtension
+type ArgoCDExtensionTemplateMeta struct {
+	// Name is the name of the generated object
+	Name string `json:"name"`
+	// Labels are added to the generated object
+	Labels map[string]string `json:"labels,omitempty"`
+	// Annotations are added to the generated object
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+const (
+	// ReasonGenerated is used when all ArgoCDExtension objects of the set have been generated
+	ReasonGenerated = "Generated"
+	// ReasonGenerationFailed is used when the ArgoCDExtension objects of the set could not be generated
+	ReasonGenerationFailed = "GenerationFailed"
+)
+
+// ArgoCDExtensionSetStatus defines the observed state of ArgoCDExtensionSet
+type ArgoCDExtensionSetStatus struct {
+	// Conditions is a list of conditions describing the set state
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Extensions lists the names of the generated ArgoCDExtension objects
+	Extensions []string `json:"extensions,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ArgoCDExtensionSet generates ArgoCDExtension objects from a template and a list of generators
+type ArgoCDExtensionSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ArgoCDExtensionSetSpec   `json:"spec,omitempty"`
+	Status ArgoCDExtensionSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ArgoCDExtensionSetList contains a list of ArgoCDExtensionSet
+type ArgoCDExtensionSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ArgoCDExtensionSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ArgoCDExtensionSet{}, &ArgoCDExtensionSetList{})
+}
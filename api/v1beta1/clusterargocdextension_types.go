@@ -0,0 +1,46 @@
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// ReasonInstancesNotReady is used when the extension is not Ready in at least one Argo CD instance
+	ReasonInstancesNotReady = "InstancesNotReady"
+)
+
+// ClusterArgoCDExtensionStatus defines the observed state of ClusterArgoCDExtension
+type ClusterArgoCDExtensionStatus struct {
+	// Conditions is a list of conditions describing the extension state
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+	// Namespaces lists the namespaces of the Argo CD instances the extension is installed into
+	Namespaces []string `json:"namespaces,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterArgoCDExtension is an extension installed into every Argo CD instance served by the controller. An
+// ArgoCDExtension with the same name and spec is managed in the namespace of every instance.
+type ClusterArgoCDExtension struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ArgoCDExtensionSpec          `json:"spec,omitempty"`
+	Status ClusterArgoCDExtensionStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterArgoCDExtensionList contains a list of ClusterArgoCDExtension
+type ClusterArgoCDExtensionList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterArgoCDExtension `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterArgoCDExtension{}, &ClusterArgoCDExtensionList{})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/argoproj/argocd-extensions/pkg/lua"
+)
+
+// lua-test runs the Lua health checks and actions shipped in an extension bundle against its fixtures
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s <extension-dir>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	results, err := lua.RunTests(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to run tests: %v\n", err)
+		os.Exit(1)
+	}
+	for _, r := range results {
+		if r.Error != nil {
+			fmt.Printf("FAIL %s: %v\n", r.Name, r.Error)
+		} else {
+			fmt.Printf("PASS %s\n", r.Name)
+		}
+	}
+	failed := lua.Failed(results)
+	fmt.Printf("%d passed, %d failed\n", len(results)-len(failed), len(failed))
+	if len(failed) > 0 {
+		os.Exit(1)
+	}
+}
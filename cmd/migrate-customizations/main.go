@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	"github.com/argoproj/argocd-extensions/pkg/migrate"
+	"github.com/argoproj/argocd-extensions/pkg/settings"
+)
+
+// migrate-customizations splits the resource customizations of argocd-cm into extension bundles and prints the
+// ArgoCDExtension objects that install them
+func main() {
+	var file, namespace, extensionsNamespace string
+	var opts migrate.Options
+	flag.StringVar(&file, "file", "", "Path to argocd-cm manifest file. The live ConfigMap is read if not specified.")
+	flag.StringVar(&namespace, "namespace", "argocd", "Namespace of the live argocd-cm ConfigMap.")
+	flag.StringVar(&extensionsNamespace, "extensions-namespace", "argocd", "Namespace of the generated ArgoCDExtension objects.")
+	flag.StringVar(&opts.OutputDir, "output", "extensions", "Directory the extension bundles are written to.")
+	flag.StringVar(&opts.Version, "version", "0.1.0", "Version of the generated extensions.")
+	flag.StringVar(&opts.RepoURL, "repo-url", "", "URL of the Git repository the bundles will be pushed to.")
+	flag.StringVar(&opts.Revision, "revision", "HEAD", "Git revision of the generated extension sources.")
+	flag.StringVar(&opts.RepoPath, "repo-path", "extensions", "Repository directory that holds the bundle directories.")
+	flag.Parse()
+	if opts.RepoURL == "" {
+		fmt.Fprintln(os.Stderr, "--repo-url is required")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cm, err := readConfigMap(file, namespace)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to read %s: %v\n", settings.ArgoCDConfigMapName, err)
+		os.Exit(1)
+	}
+	bundles, warnings, err := migrate.Split(cm)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to split resource customizations: %v\n", err)
+		os.Exit(1)
+	}
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "WARN %s\n", w)
+	}
+
+	for _, b := range bundles {
+		if err := migrate.WriteBundle(b, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write bundle %s: %v\n", b.Name(), err)
+			os.Exit(1)
+		}
+		data, err := yaml.Marshal(migrate.Extension(b, extensionsNamespace, opts))
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to marshal extension %s: %v\n", b.Name(), err)
+			os.Exit(1)
+		}
+		fmt.Printf("---\n%s", data)
+	}
+	fmt.Fprintf(os.Stderr, "migrated %d resource customizations to %s\n", len(bundles), opts.OutputDir)
+}
+
+func readConfigMap(file string, namespace string) (*corev1.ConfigMap, error) {
+	if file != "" {
+		return migrate.ReadConfigMap(file)
+	}
+	config, err := ctrl.GetConfig()
+	if err != nil {
+		return nil, err
+	}
+	c, err := client.New(config, client.Options{})
+	if err != nil {
+		return nil, err
+	}
+	var cm corev1.ConfigMap
+	key := types.NamespacedName{Namespace: namespace, Name: settings.ArgoCDConfigMapName}
+	if err := c.Get(context.Background(), key, &cm); err != nil {
+		return nil, err
+	}
+	return &cm, nil
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+)
+
+// reconcileAgent installs the artifact published by the coordinator and reports the state of this replica. The
+// conditions, settings and applied objects of the extension are left to the coordinator.
+func (r *ArgoCDExtensionReconciler) reconcileAgent(ctx context.Context, original *extensionv1.ArgoCDExtension, ext *extensionv1.ArgoCDExtension) (ctrl.Result, error) {
+	extensionCtx := extension.NewExtensionContext(ext, r.ExtensionsPath, extension.Options{})
+
+	if findIndex(ext.Finalizers, finalizerName) > -1 && ext.DeletionTimestamp != nil {
+		if findReplica(ext.Status.Replicas, r.Replica.Pod) == -1 && !extensionCtx.Installed() {
+			return ctrl.Result{}, nil
+		}
+		if err := extensionCtx.ProcessDeletion(ctx); err != nil {
+			r.Recorder.Eventf(ext, corev1.EventTypeWarning, eventReasonCleanupFailed, "Failed to clean up extension: %v", err)
+			return ctrl.Result{}, err
+		}
+		r.Recorder.Event(ext, corev1.EventTypeNormal, eventReasonCleanedUp, fmt.Sprintf("Removed extension files from %s", r.Replica.Pod))
+		// the coordinator removes the finalizer once all live replicas removed their entries
+		if _, err := r.releaseReplica(ctx, ext); err != nil {
+			return ctrl.Result{}, err
+		}
+		metrics.DeleteExtension(ext.Namespace, ext.Name)
+		return ctrl.Result{}, r.Client.Patch(ctx, ext, r.statusPatch(original))
+	}
+
+	condition := metav1.Condition{Type: extensionv1.ConditionReady, ObservedGeneration: ext.Generation}
+	var revisions []string
+	err := r.installArtifact(ctx, ext, extensionCtx)
+	switch {
+	case ext.Status.Artifact == nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = extensionv1.ReasonArtifactPending
+		condition.Message = "Waiting for the coordinator to publish the extension artifact"
+	case err != nil:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = extensionv1.ReasonProcessingFailed
+		condition.Message = err.Error()
+		if prev, ok := r.previousMessage(original); !ok || prev != condition.Message {
+			r.Recorder.Event(ext, corev1.EventTypeWarning, failureEventReason(err, condition.Reason), condition.Message)
+		}
+	default:
+		revisions = ext.Status.Artifact.Revisions
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = extensionv1.ReasonProcessed
+		condition.Message = fmt.Sprintf("Installed artifact %s", ext.Status.Artifact.Digest)
+		if changes := extensionCtx.Changes(); changes != "" {
+			r.Recorder.Event(ext, corev1.EventTypeNormal, eventReasonInstalled,
+				fmt.Sprintf("Installed %d extension files into %s: %s", len(extensionCtx.Files()), r.Replica.Pod, changes))
+		}
+	}
+	metrics.ObserveReconcile(ext.Namespace, ext.Name, condition.Reason, condition.Status == metav1.ConditionTrue)
+	if _, err := r.reportReplica(ctx, ext, condition, revisions); err != nil {
+		return ctrl.Result{}, err
+	}
+	if !reflect.DeepEqual(ext.Status, original.Status) {
+		if err := r.Client.Patch(ctx, ext, r.statusPatch(original)); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+	r.recordAttempt(ext, condition)
+	// new artifacts are published in the extension status, which triggers the reconciliation
+	return ctrl.Result{}, nil
+}
+
+// installArtifact installs the artifact referenced in the extension status unless it is installed
+func (r *ArgoCDExtensionReconciler) installArtifact(ctx context.Context, ext *extensionv1.ArgoCDExtension, extensionCtx artifactInstaller) error {
+	artifact := ext.Status.Artifact
+	if artifact == nil {
+		return nil
+	}
+	return extensionCtx.InstallArtifact(ctx, artifact.Digest, artifact.Revisions, func(ctx context.Context) ([]byte, error) {
+		return r.fetchArtifact(ctx, ext)
+	})
+}
+
+// artifactInstaller is implemented by the extension context
+type artifactInstaller interface {
+	InstallArtifact(ctx context.Context, digest string, revisions []string, fetch func(context.Context) ([]byte, error)) error
+	Changes() string
+	Files() []string
+}
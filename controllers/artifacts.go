@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+const (
+	// artifactLabel marks the ConfigMaps holding chunks of extension artifacts
+	artifactLabel = "argocd-extensions.argoproj.io/artifact"
+	// artifactChunkKey is the ConfigMap binaryData key holding the artifact chunk
+	artifactChunkKey = "chunk"
+	// artifactChunkSize keeps the base64 encoded chunks below the 1MiB object size limit
+	artifactChunkSize = 512 * 1024
+	// maxArtifactNamePrefix leaves room for the digest and the chunk index in the ConfigMap names
+	maxArtifactNamePrefix = 200
+)
+
+// artifactPackager is implemented by the extension context
+type artifactPackager interface {
+	Package(ctx context.Context) ([]byte, error)
+	Revisions() []string
+}
+
+// publishArtifact packages the installed extension files, stores the archive in immutable ConfigMaps named after its
+// digest and references them in the extension status. ConfigMaps of previous artifacts are deleted.
+func (r *ArgoCDExtensionReconciler) publishArtifact(ctx context.Context, ext *extensionv1.ArgoCDExtension, packager artifactPackager) error {
+	data, err := packager.Package(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to package extension files: %v", err)
+	}
+	digest := extension.Digest(data)
+	if ext.Status.Artifact != nil && ext.Status.Artifact.Digest == digest {
+		// new revisions of the sources might ship the same files
+		ext.Status.Artifact.Revisions = packager.Revisions()
+	} else {
+		artifact := &extensionv1.ArtifactStatus{Digest: digest, Revisions: packager.Revisions(), Size: int64(len(data))}
+		for i := 0; i == 0 || i*artifactChunkSize < len(data); i++ {
+			end := (i + 1) * artifactChunkSize
+			if end > len(data) {
+				end = len(data)
+			}
+			name, err := r.storeChunk(ctx, ext, digest, i, data[i*artifactChunkSize:end])
+			if err != nil {
+				return fmt.Errorf("failed to store artifact: %v", err)
+			}
+			artifact.ConfigMaps = append(artifact.ConfigMaps, name)
+		}
+		ext.Status.Artifact = artifact
+	}
+	return r.pruneArtifacts(ctx, ext)
+}
+
+// storeChunk creates the ConfigMap holding the artifact chunk unless it exists
+func (r *ArgoCDExtensionReconciler) storeChunk(ctx context.Context, ext *extensionv1.ArgoCDExtension, digest string, index int, chunk []byte) (string, error) {
+	prefix := ext.Name
+	if len(prefix) > maxArtifactNamePrefix {
+		prefix = prefix[:maxArtifactNamePrefix]
+	}
+	hash := strings.TrimPrefix(digest, "sha256:")
+	immutable := true
+	cm := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: ext.Namespace,
+			Name:      fmt.Sprintf("%s-artifact-%s-%d", strings.TrimSuffix(prefix, "."), hash[:12], index),
+			Labels:    map[string]string{artifactLabel: "true"},
+		},
+		Immutable:  &immutable,
+		BinaryData: map[string][]byte{artifactChunkKey: chunk},
+	}
+	if err := controllerutil.SetControllerReference(ext, cm, r.Scheme); err != nil {
+		return "", err
+	}
+	if err := r.Create(ctx, cm); err != nil && !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return cm.Name, nil
+}
+
+// pruneArtifacts deletes the artifact ConfigMaps of the extension that are not referenced in its status
+func (r *ArgoCDExtensionReconciler) pruneArtifacts(ctx context.Context, ext *extensionv1.ArgoCDExtension) error {
+	current := map[string]bool{}
+	if ext.Status.Artifact != nil {
+		for _, name := range ext.Status.Artifact.ConfigMaps {
+			current[name] = true
+		}
+	}
+	var list corev1.ConfigMapList
+	if err := r.List(ctx, &list, client.InNamespace(ext.Namespace), client.MatchingLabels{artifactLabel: "true"}); err != nil {
+		return err
+	}
+	for i := range list.Items {
+		cm := &list.Items[i]
+		if current[cm.Name] || !metav1.IsControlledBy(cm, ext) {
+			continue
+		}
+		if err := r.Delete(ctx, cm); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete artifact %s: %v", cm.Name, err)
+		}
+	}
+	return nil
+}
+
+// fetchArtifact concatenates the chunks of the artifact referenced in the extension status
+func (r *ArgoCDExtensionReconciler) fetchArtifact(ctx context.Context, ext *extensionv1.ArgoCDExtension) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, name := range ext.Status.Artifact.ConfigMaps {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ext.Namespace, Name: name}, &cm); err != nil {
+			return nil, fmt.Errorf("failed to get artifact %s: %v", name, err)
+		}
+		buf.Write(cm.BinaryData[artifactChunkKey])
+	}
+	return buf.Bytes(), nil
+}
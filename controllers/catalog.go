@@ -0,0 +1,134 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/catalog"
+	"github.com/argoproj/argocd-extensions/pkg/config"
+)
+
+const (
+	// defaultCatalogRefreshInterval is the delay between catalog index updates if the catalog does not specify one
+	defaultCatalogRefreshInterval = 10 * time.Minute
+)
+
+// ArgoCDExtensionCatalogReconciler reconciles a ArgoCDExtensionCatalog object
+type ArgoCDExtensionCatalogReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// Settings holds the timeouts of fetching catalog indexes
+	Settings *config.Store
+	// Namespaces restricts the reconciled objects to the namespaces matching a label selector
+	Namespaces *NamespaceFilter
+}
+
+func (r *ArgoCDExtensionCatalogReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var original extensionv1.ArgoCDExtensionCatalog
+	if err := r.Get(ctx, req.NamespacedName, &original); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	if original.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	cat := original.DeepCopy()
+
+	interval := defaultCatalogRefreshInterval
+	if cat.Spec.RefreshInterval != nil && cat.Spec.RefreshInterval.Duration > 0 {
+		interval = cat.Spec.RefreshInterval.Duration
+	}
+	// skip fetching the index if it is up to date with the spec and has been fetched recently
+	if c := meta.FindStatusCondition(cat.Status.Conditions, extensionv1.ConditionReady); c != nil &&
+		c.Reason == extensionv1.ReasonIndexUpdated && c.ObservedGeneration == cat.Generation && cat.Status.LastUpdateTime != nil {
+		if remaining := time.Until(cat.Status.LastUpdateTime.Add(interval)); remaining > 0 {
+			return ctrl.Result{RequeueAfter: remaining}, nil
+		}
+	}
+
+	readyCondition := metav1.Condition{Type: extensionv1.ConditionReady, ObservedGeneration: cat.Generation}
+	timeouts := r.Settings.Get().Timeouts
+	revision, extensions, err := catalog.Fetch(ctx, cat.Spec.Source,
+		catalog.Options{ResolveTimeout: timeouts.Resolve.Duration, DownloadTimeout: timeouts.Download.Duration})
+	if err != nil {
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = extensionv1.ReasonIndexFailed
+		readyCondition.Message = fmt.Sprintf("Failed to update index: %v", err)
+	} else {
+		now := metav1.Now()
+		readyCondition.Status = metav1.ConditionTrue
+		readyCondition.Reason = extensionv1.ReasonIndexUpdated
+		readyCondition.Message = fmt.Sprintf("Index lists %d extensions", len(extensions))
+		cat.Status.Revision = revision
+		cat.Status.Extensions = extensions
+		cat.Status.LastUpdateTime = &now
+	}
+	meta.SetStatusCondition(&cat.Status.Conditions, readyCondition)
+	if !reflect.DeepEqual(cat.Status, original.Status) {
+		err := r.Client.Patch(ctx, cat, client.MergeFrom(&original))
+		return ctrl.Result{RequeueAfter: interval}, err
+	}
+	return ctrl.Result{RequeueAfter: interval}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ArgoCDExtensionCatalogReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithEventFilter(r.Namespaces.Predicate()).
+		For(&extensionv1.ArgoCDExtensionCatalog{}).
+		Complete(r)
+}
+
+// resolveCatalog returns the extension with the sources of the catalog version it references and updates the
+// available version in its status. Extensions that do not reference a catalog are returned as is.
+func (r *ArgoCDExtensionReconciler) resolveCatalog(ctx context.Context, ext *extensionv1.ArgoCDExtension) (*extensionv1.ArgoCDExtension, error) {
+	ref := ext.Spec.Catalog
+	if ref == nil {
+		ext.Status.AvailableVersion = ""
+		return ext, nil
+	}
+	if len(ext.Spec.Sources) > 0 {
+		return ext, fmt.Errorf("only one of sources and catalog can be specified")
+	}
+	var cat extensionv1.ArgoCDExtensionCatalog
+	if err := r.Get(ctx, types.NamespacedName{Namespace: ext.Namespace, Name: ref.Name}, &cat); err != nil {
+		return ext, fmt.Errorf("failed to get catalog %s: %v", ref.Name, err)
+	}
+	if cat.Status.LastUpdateTime == nil {
+		return ext, fmt.Errorf("catalog %s index has not been fetched yet", ref.Name)
+	}
+	selected, available, err := catalog.Resolve(cat.Status.Extensions, *ref, r.ArgoCDVersion)
+	if err != nil {
+		return ext, err
+	}
+	ext.Status.AvailableVersion = available
+	resolved := ext.DeepCopy()
+	resolved.Spec.Sources = selected.Sources
+	return resolved, nil
+}
+
+// extensionsForCatalog returns the extensions that reference the catalog
+func (r *ArgoCDExtensionReconciler) extensionsForCatalog(obj client.Object) []reconcile.Request {
+	var list extensionv1.ArgoCDExtensionList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.Log.Error(err, "Failed to list extensions referencing catalog", "catalog", obj.GetName())
+		return nil
+	}
+	var res []reconcile.Request
+	for _, ext := range list.Items {
+		if ext.Spec.Catalog != nil && ext.Spec.Catalog.Name == obj.GetName() {
+			res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}})
+		}
+	}
+	return res
+}
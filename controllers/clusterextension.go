@@ -0,0 +1,219 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/settings"
+)
+
+const (
+	// clusterExtensionLabel holds the name of the ClusterArgoCDExtension that manages the ArgoCDExtension
+	clusterExtensionLabel = "argocd-extensions.argoproj.io/cluster-extension"
+)
+
+// ClusterArgoCDExtensionReconciler reconciles a ClusterArgoCDExtension object
+type ClusterArgoCDExtensionReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// ArgoCDNamespace is the namespace of the single Argo CD instance served by the controller, if any
+	ArgoCDNamespace string
+	// Namespaces lists the watched namespaces, all namespaces are watched if empty
+	Namespaces []string
+	// NamespaceFilter restricts the watched namespaces to the ones matching a label selector
+	NamespaceFilter *NamespaceFilter
+}
+
+func (r *ClusterArgoCDExtensionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var original extensionv1.ClusterArgoCDExtension
+	if err := r.Get(ctx, req.NamespacedName, &original); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// managed extensions are garbage collected using owner references
+	if original.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	ce := original.DeepCopy()
+
+	readyCondition := metav1.Condition{Type: extensionv1.ConditionReady, ObservedGeneration: ce.Generation}
+	namespaces, err := r.instanceNamespaces(ctx)
+	if err == nil {
+		err = r.sync(ctx, ce, namespaces)
+	}
+	if err != nil {
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = extensionv1.ReasonProcessingFailed
+		readyCondition.Message = err.Error()
+	} else {
+		ce.Status.Namespaces = namespaces
+		notReady, err := r.notReady(ctx, ce, namespaces)
+		switch {
+		case err != nil:
+			readyCondition.Status = metav1.ConditionFalse
+			readyCondition.Reason = extensionv1.ReasonProcessingFailed
+			readyCondition.Message = err.Error()
+		case len(notReady) > 0:
+			readyCondition.Status = metav1.ConditionFalse
+			readyCondition.Reason = extensionv1.ReasonInstancesNotReady
+			readyCondition.Message = fmt.Sprintf("Extension is not ready in namespaces: %s", strings.Join(notReady, ", "))
+		default:
+			readyCondition.Status = metav1.ConditionTrue
+			readyCondition.Reason = extensionv1.ReasonProcessed
+			readyCondition.Message = fmt.Sprintf("Extension is ready in %d Argo CD instances", len(namespaces))
+		}
+	}
+	meta.SetStatusCondition(&ce.Status.Conditions, readyCondition)
+	if !reflect.DeepEqual(ce.Status, original.Status) {
+		return ctrl.Result{}, r.Client.Patch(ctx, ce, client.MergeFrom(&original))
+	}
+	return ctrl.Result{}, nil
+}
+
+// instanceNamespaces returns the namespaces of the Argo CD instances served by the controller: the Argo CD namespace
+// if configured, otherwise the watched namespaces that hold the argocd-cm ConfigMap
+func (r *ClusterArgoCDExtensionReconciler) instanceNamespaces(ctx context.Context) ([]string, error) {
+	if r.ArgoCDNamespace != "" {
+		return []string{r.ArgoCDNamespace}, nil
+	}
+	candidates := r.Namespaces
+	if len(candidates) == 0 {
+		var list corev1.NamespaceList
+		if err := r.List(ctx, &list); err != nil {
+			return nil, err
+		}
+		for _, ns := range list.Items {
+			candidates = append(candidates, ns.Name)
+		}
+	}
+	var res []string
+	for _, ns := range candidates {
+		if matches, err := r.NamespaceFilter.Matches(ctx, ns); err != nil {
+			return nil, err
+		} else if !matches {
+			continue
+		}
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: settings.ArgoCDConfigMapName}, &cm); err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, err
+		}
+		res = append(res, ns)
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// sync creates or updates the extension in every instance namespace and deletes the extensions previously managed
+// in other namespaces
+func (r *ClusterArgoCDExtensionReconciler) sync(ctx context.Context, ce *extensionv1.ClusterArgoCDExtension, namespaces []string) error {
+	desired := map[string]bool{}
+	for _, ns := range namespaces {
+		desired[ns] = true
+		ext := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: ns, Name: ce.Name}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, ext, func() error {
+			if !ext.CreationTimestamp.IsZero() && !metav1.IsControlledBy(ext, ce) {
+				return fmt.Errorf("extension %s/%s already exists and is not managed by the cluster extension", ns, ext.Name)
+			}
+			if ext.Labels == nil {
+				ext.Labels = map[string]string{}
+			}
+			ext.Labels[clusterExtensionLabel] = ce.Name
+			ext.Spec = ce.Spec
+			if ext.DeletionTimestamp == nil && findIndex(ext.Finalizers, finalizerName) == -1 {
+				ext.Finalizers = append(ext.Finalizers, finalizerName)
+			}
+			return controllerutil.SetControllerReference(ce, ext, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to update extension %s/%s: %v", ns, ce.Name, err)
+		}
+	}
+
+	var existing extensionv1.ArgoCDExtensionList
+	if err := r.List(ctx, &existing, client.MatchingLabels{clusterExtensionLabel: ce.Name}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		ext := &existing.Items[i]
+		if desired[ext.Namespace] || !metav1.IsControlledBy(ext, ce) || ext.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, ext); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete extension %s/%s: %v", ext.Namespace, ext.Name, err)
+		}
+	}
+	return nil
+}
+
+// notReady returns the namespaces in which the managed extension is not Ready for the current spec yet
+func (r *ClusterArgoCDExtensionReconciler) notReady(ctx context.Context, ce *extensionv1.ClusterArgoCDExtension, namespaces []string) ([]string, error) {
+	var res []string
+	for _, ns := range namespaces {
+		var ext extensionv1.ArgoCDExtension
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ns, Name: ce.Name}, &ext); errors.IsNotFound(err) {
+			// the cache has not observed the created extension yet
+			res = append(res, ns)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		c := meta.FindStatusCondition(ext.Status.Conditions, extensionv1.ConditionReady)
+		if c == nil || c.Status != metav1.ConditionTrue || c.ObservedGeneration != ext.Generation {
+			res = append(res, ns)
+		}
+	}
+	return res, nil
+}
+
+// allClusterExtensions returns all cluster extensions, so they are installed into new Argo CD instances
+func (r *ClusterArgoCDExtensionReconciler) allClusterExtensions(_ client.Object) []reconcile.Request {
+	var list extensionv1.ClusterArgoCDExtensionList
+	if err := r.List(context.Background(), &list); err != nil {
+		ctrl.Log.Error(err, "Failed to list cluster extensions")
+		return nil
+	}
+	var res []reconcile.Request
+	for _, ce := range list.Items {
+		res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Name: ce.Name}})
+	}
+	return res
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterArgoCDExtensionReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	b := ctrl.NewControllerManagedBy(mgr).
+		For(&extensionv1.ClusterArgoCDExtension{}).
+		Owns(&extensionv1.ArgoCDExtension{})
+	if r.ArgoCDNamespace == "" {
+		// creating the argocd-cm ConfigMap or a namespace adds an Argo CD instance
+		instances := handler.EnqueueRequestsFromMapFunc(func(obj client.Object) []reconcile.Request {
+			if cm, ok := obj.(*corev1.ConfigMap); ok && cm.Name != settings.ArgoCDConfigMapName {
+				return nil
+			}
+			return r.allClusterExtensions(obj)
+		})
+		b = b.Watches(&source.Kind{Type: &corev1.ConfigMap{}}, instances)
+		if len(r.Namespaces) == 0 || r.NamespaceFilter.Enabled() {
+			b = b.Watches(&source.Kind{Type: &corev1.Namespace{}}, instances)
+		}
+	}
+	return b.Complete(r)
+}
@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/yaml"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// resolveConfig returns the configuration values of the extension, merging the ConfigMap values with the inline
+// values. Returns nil if the extension has no configuration.
+func (r *ArgoCDExtensionReconciler) resolveConfig(ctx context.Context, ext *extensionv1.ArgoCDExtension) (map[string]interface{}, error) {
+	config := ext.Spec.Config
+	if config == nil {
+		return nil, nil
+	}
+	values := map[string]interface{}{}
+	if ref := config.ConfigMapRef; ref != nil {
+		var cm corev1.ConfigMap
+		if err := r.Get(ctx, types.NamespacedName{Namespace: ext.Namespace, Name: ref.Name}, &cm); err != nil {
+			return nil, fmt.Errorf("failed to get config ConfigMap %s: %v", ref.Name, err)
+		}
+		if ref.Key == "" {
+			for k, v := range cm.Data {
+				values[k] = v
+			}
+		} else {
+			data, ok := cm.Data[ref.Key]
+			if !ok {
+				return nil, fmt.Errorf("config ConfigMap %s has no key %s", ref.Name, ref.Key)
+			}
+			if err := yaml.Unmarshal([]byte(data), &values); err != nil {
+				return nil, fmt.Errorf("failed to parse key %s of config ConfigMap %s: %v", ref.Key, ref.Name, err)
+			}
+			if values == nil {
+				values = map[string]interface{}{}
+			}
+		}
+	}
+	if config.Values != nil && len(config.Values.Raw) > 0 {
+		inline := map[string]interface{}{}
+		if err := json.Unmarshal(config.Values.Raw, &inline); err != nil {
+			return nil, fmt.Errorf("config values must be an object: %v", err)
+		}
+		for k, v := range inline {
+			values[k] = v
+		}
+	}
+	return values, nil
+}
+
+// extensionsForConfigMap returns the extensions that take configuration values from the ConfigMap
+func (r *ArgoCDExtensionReconciler) extensionsForConfigMap(obj client.Object) []reconcile.Request {
+	var list extensionv1.ArgoCDExtensionList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetNamespace())); err != nil {
+		ctrl.Log.Error(err, "Failed to list extensions referencing ConfigMap", "configmap", obj.GetName())
+		return nil
+	}
+	var res []reconcile.Request
+	for _, ext := range list.Items {
+		if c := ext.Spec.Config; c != nil && c.ConfigMapRef != nil && c.ConfigMapRef.Name == obj.GetName() {
+			res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}})
+		}
+	}
+	return res
+}
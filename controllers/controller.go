@@ -2,20 +2,38 @@ package controllers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
-	extensionv1 "github.com/argoproj/argocd-extensions/api/v1alpha1"
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/config"
 	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+	"github.com/argoproj/argocd-extensions/pkg/tracing"
 )
 
 const (
 	finalizerName = "extensions-finalizer.argocd.argoproj.io"
+
+	// progressingRequeueInterval is the delay before the health of progressing applied objects is checked again
+	progressingRequeueInterval = 10 * time.Second
 )
 
 // ArgoCDExtensionReconciler reconciles a ArgoCDExtension object
@@ -23,6 +41,30 @@ type ArgoCDExtensionReconciler struct {
 	client.Client
 	Scheme         *runtime.Scheme
 	ExtensionsPath string
+	// ArgoCDVersion is the version of the running Argo CD used to check extensions compatibility
+	ArgoCDVersion string
+	// VerifyLua enables refusing to install extensions whose Lua tests fail
+	VerifyLua bool
+	// Recorder emits events describing installations, failures and cleanups of extensions
+	Recorder record.EventRecorder
+	// Settings holds the refresh interval, timeouts and concurrency of reconciliations
+	Settings *config.Store
+	// ArgoCDNamespace is the namespace of the Argo CD ConfigMaps, defaults to the namespace of every extension
+	ArgoCDNamespace string
+	// SettingsClient reads and updates the Argo CD ConfigMaps if the ArgoCDNamespace is not watched, defaults to Client
+	SettingsClient client.Client
+	// Namespaces restricts the installed extensions to the namespaces matching a label selector
+	Namespaces *NamespaceFilter
+	// InstanceLabels identifies the served Argo CD instance, matched by the target selector of extensions
+	InstanceLabels map[string]string
+	// Replica identifies the Argo CD server replica reported in the extension status, replicas are not reported if nil
+	Replica *ReplicaIdentity
+	// Mode is either standalone, coordinator to publish the installed files as artifacts, or agent to install the
+	// published artifacts
+	Mode string
+
+	// attempts holds the failedAttempt of the extensions this replica failed to install, by namespace and name
+	attempts sync.Map
 }
 
 func findIndex(in []string, item string) int {
@@ -35,42 +77,236 @@ func findIndex(in []string, item string) int {
 }
 
 func (r *ArgoCDExtensionReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	ctx, span := tracing.Start(ctx, "Reconcile",
+		tracing.ExtensionNamespace.String(req.Namespace), tracing.ExtensionName.String(req.Name))
+	defer span.End()
+
 	var original extensionv1.ArgoCDExtension
 	if err := r.Get(ctx, req.NamespacedName, &original); err != nil {
 		return ctrl.Result{}, client.IgnoreNotFound(err)
 	}
 	ext := original.DeepCopy()
 
-	extensionCtx := extension.NewExtensionContext(ext, r.ExtensionsPath)
+	// extensions targeting other Argo CD instances are left to their controllers, but deleted extensions are released
+	// by any instance since no instance might match the target
+	deleting := ext.DeletionTimestamp != nil && findIndex(ext.Finalizers, finalizerName) > -1
+	if !deleting {
+		if targeted, err := r.isTargeted(ext); err != nil {
+			return ctrl.Result{}, err
+		} else if !targeted {
+			return ctrl.Result{}, r.removeUntargeted(ctx, ext)
+		}
+	}
+	if r.Mode == config.ModeAgent {
+		return r.reconcileAgent(ctx, &original, ext)
+	}
 
-	if index := findIndex(ext.Finalizers, finalizerName); index > -1 && ext.DeletionTimestamp != nil {
-		if err := extensionCtx.ProcessDeletion(); err != nil {
+	// the sources of extensions referencing a catalog are resolved before the extension context is created
+	resolved, catalogErr := r.resolveCatalog(ctx, ext)
+	values, configErr := r.resolveConfig(ctx, ext)
+	settings := r.Settings.Get()
+	extensionCtx := extension.NewExtensionContext(resolved, r.ExtensionsPath, extension.Options{
+		ArgoCDVersion:   r.ArgoCDVersion,
+		VerifyLua:       r.VerifyLua,
+		Config:          values,
+		ResolveTimeout:  settings.Timeouts.Resolve.Duration,
+		DownloadTimeout: settings.Timeouts.Download.Duration,
+	})
+
+	if deleting {
+		// the finalizer is removed by the last replica cleaning up the extension
+		if released, err := r.replicaReleased(ctx, ext); err != nil || released {
+			return ctrl.Result{}, err
+		}
+		if dependents, err := listDependents(ctx, r.Client, ext.Namespace, ext.Name); err != nil {
+			return ctrl.Result{}, err
+		} else if len(dependents) > 0 {
+			r.Recorder.Eventf(ext, corev1.EventTypeWarning, eventReasonDependentsExist,
+				"Deleting extension that %s depend on", strings.Join(dependents, ", "))
+		}
+		cleanup := func() error {
+			if err := r.deleteSettings(ctx, ext); err != nil {
+				return err
+			}
+			if err := r.deleteManifests(ctx, ext); err != nil {
+				return err
+			}
+			return extensionCtx.ProcessDeletion(ctx)
+		}
+		if err := cleanup(); err != nil {
+			r.Recorder.Eventf(ext, corev1.EventTypeWarning, eventReasonCleanupFailed, "Failed to clean up extension: %v", err)
 			return ctrl.Result{}, err
 		}
+		r.Recorder.Event(ext, corev1.EventTypeNormal, eventReasonCleanedUp, "Removed extension files, settings and applied objects")
+		if remaining, err := r.releaseReplica(ctx, ext); err != nil {
+			return ctrl.Result{}, err
+		} else if remaining > 0 {
+			return ctrl.Result{}, r.Client.Patch(ctx, ext, r.statusPatch(&original))
+		}
+		index := findIndex(ext.Finalizers, finalizerName)
 		ext.Finalizers = append(ext.Finalizers[:index], ext.Finalizers[index+1:]...)
-		err := r.Client.Update(ctx, ext)
-		return ctrl.Result{}, err
+		if err := r.Client.Update(ctx, ext); err != nil {
+			return ctrl.Result{}, err
+		}
+		metrics.DeleteExtension(ext.Namespace, ext.Name)
+		return ctrl.Result{}, nil
 	}
 
-	readyCondition := extensionv1.ArgoCDExtensionCondition{Type: extensionv1.ConditionReady}
-	if err := extensionCtx.Process(ctx); err != nil {
+	readyCondition := metav1.Condition{Type: extensionv1.ConditionReady, ObservedGeneration: ext.Generation}
+	install := func() error {
+		if err := r.checkDependencies(ctx, ext); err != nil {
+			return err
+		}
+		if catalogErr != nil {
+			return catalogErr
+		}
+		if configErr != nil {
+			return configErr
+		}
+		if err := extensionCtx.Process(ctx); err != nil {
+			return err
+		}
+		generated, err := extensionCtx.Settings()
+		if err != nil {
+			return err
+		}
+		if err := r.updateSettings(ctx, ext, generated); err != nil {
+			return err
+		}
+		rbacCondition, err := r.updateRBAC(ctx, ext, generated)
+		if err != nil {
+			return err
+		}
+		if rbacCondition != nil {
+			meta.SetStatusCondition(&ext.Status.Conditions, *rbacCondition)
+		} else {
+			meta.RemoveStatusCondition(&ext.Status.Conditions, extensionv1.ConditionRBACConfigured)
+		}
+		healthCondition, err := r.applyManifests(ctx, ext, extensionCtx.Objects())
+		if err != nil {
+			return err
+		}
+		if healthCondition != nil {
+			meta.SetStatusCondition(&ext.Status.Conditions, *healthCondition)
+		} else {
+			meta.RemoveStatusCondition(&ext.Status.Conditions, extensionv1.ConditionManifestsHealthy)
+		}
+		if r.Mode == config.ModeCoordinator {
+			return r.publishArtifact(ctx, ext, extensionCtx)
+		}
+		return nil
+	}
+	err := install()
+	if changes := extensionCtx.Changes(); changes != "" {
+		r.Recorder.Event(ext, corev1.EventTypeNormal, eventReasonSourcesChanged, changes)
+	}
+	if err != nil {
+		tracing.SetError(span, err)
 		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = extensionv1.ReasonProcessingFailed
+		switch {
+		case errors.Is(err, extension.ErrIncompatible):
+			readyCondition.Reason = extensionv1.ReasonIncompatible
+		case errors.Is(err, errDependencyCycle):
+			readyCondition.Reason = extensionv1.ReasonDependencyCycle
+		case errors.Is(err, errDependenciesNotReady):
+			readyCondition.Reason = extensionv1.ReasonDependenciesNotReady
+		}
 		readyCondition.Message = err.Error()
+		// failures are retried on every change, so only new failures are reported
+		if prev, ok := r.previousMessage(&original); !ok || prev != readyCondition.Message {
+			r.Recorder.Event(ext, corev1.EventTypeWarning, failureEventReason(err, readyCondition.Reason), readyCondition.Message)
+		}
 	} else {
 		readyCondition.Status = metav1.ConditionTrue
-		readyCondition.Message = fmt.Sprintf("Successfully processed %d extension sources", len(original.Spec.Sources))
+		readyCondition.Reason = extensionv1.ReasonProcessed
+		readyCondition.Message = fmt.Sprintf("Successfully processed %d extension sources", len(resolved.Spec.Sources))
+		if r.Mode == config.ModeCoordinator {
+			// the condition is aggregated again from the published artifact when agents report their state
+			readyCondition = publishedCondition(ext)
+		}
+		ext.Status.Extension = toExtensionMetadata(extensionCtx.Manifest())
+		ext.Status.Warnings = extensionCtx.Warnings()
+		if objects := extensionCtx.Objects(); len(objects) > 0 && !ext.Spec.ApplyManifests {
+			ext.Status.Warnings = append(ext.Status.Warnings, fmt.Sprintf(
+				"extension ships %d Kubernetes objects that are not applied unless spec.applyManifests is enabled", len(objects)))
+		}
+		if extensionCtx.Changes() != "" {
+			message := fmt.Sprintf("Installed %d extension files", len(extensionCtx.Files()))
+			if manifest := extensionCtx.Manifest(); manifest != nil {
+				message = fmt.Sprintf("Installed %s %s, %d extension files", manifest.Name, manifest.Version, len(extensionCtx.Files()))
+			}
+			r.Recorder.Event(ext, corev1.EventTypeNormal, eventReasonInstalled, message)
+		}
 	}
-	ext.Status.Conditions = []extensionv1.ArgoCDExtensionCondition{readyCondition}
-	if !reflect.DeepEqual(ext.Status, original.Status) {
-		err := r.Client.Patch(ctx, ext, client.MergeFrom(&original))
+	metrics.ObserveReconcile(ext.Namespace, ext.Name, readyCondition.Reason, readyCondition.Status == metav1.ConditionTrue)
+	own := readyCondition
+	readyCondition, err = r.reportReplica(ctx, ext, readyCondition, extensionCtx.Revisions())
+	if err != nil {
 		return ctrl.Result{}, err
 	}
-	return ctrl.Result{}, nil
+	meta.SetStatusCondition(&ext.Status.Conditions, readyCondition)
+
+	// sources are resolved again periodically to pick up new revisions of branches and retry failed downloads
+	result := ctrl.Result{RequeueAfter: settings.RefreshInterval.Duration}
+	if c := meta.FindStatusCondition(ext.Status.Conditions, extensionv1.ConditionManifestsHealthy); c != nil && c.Reason == extensionv1.ReasonProgressing {
+		result.RequeueAfter = progressingRequeueInterval
+	}
+	if !reflect.DeepEqual(ext.Status, original.Status) {
+		if err := r.Client.Patch(ctx, ext, r.statusPatch(&original)); err != nil {
+			return result, err
+		}
+	}
+	r.recordAttempt(ext, own)
+	return result, nil
+}
+
+func toExtensionMetadata(manifest *extension.Manifest) *extensionv1.ExtensionMetadata {
+	if manifest == nil {
+		return nil
+	}
+	return &extensionv1.ExtensionMetadata{
+		Name:          manifest.Name,
+		Version:       manifest.Version,
+		Description:   manifest.Description,
+		Type:          string(manifest.Type),
+		ArgoCDVersion: manifest.ArgoCDVersion,
+	}
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *ArgoCDExtensionReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&extensionv1.ArgoCDExtension{}).
-		Complete(r)
+	if r.Mode == config.ModeAgent {
+		// agents only install the artifacts published in the extension status
+		return ctrl.NewControllerManagedBy(mgr).
+			WithOptions(controller.Options{MaxConcurrentReconciles: r.Settings.Get().MaxConcurrentReconciles}).
+			WithEventFilter(r.Namespaces.Predicate()).
+			For(&extensionv1.ArgoCDExtension{}).
+			Complete(r)
+	}
+	var forOptions []builder.ForOption
+	var dependencyOptions []builder.WatchesOption
+	if r.Mode == config.ModeCoordinator {
+		// the states reported by the agents are aggregated by a separate controller that does not resolve sources
+		if err := ctrl.NewControllerManagedBy(mgr).
+			Named("argocdextension-replicas").
+			WithEventFilter(r.Namespaces.Predicate()).
+			For(&extensionv1.ArgoCDExtension{}, builder.WithPredicates(replicasChangedPredicate)).
+			Complete(reconcile.Func(r.reconcileReplicas)); err != nil {
+			return err
+		}
+		forOptions = append(forOptions, builder.WithPredicates(specChangedPredicate()))
+		dependencyOptions = append(dependencyOptions, builder.WithPredicates(readyChangedPredicate()))
+	}
+	b := ctrl.NewControllerManagedBy(mgr).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.Settings.Get().MaxConcurrentReconciles}).
+		WithEventFilter(r.Namespaces.Predicate()).
+		For(&extensionv1.ArgoCDExtension{}, forOptions...).
+		Watches(&source.Kind{Type: &extensionv1.ArgoCDExtensionCatalog{}}, handler.EnqueueRequestsFromMapFunc(r.extensionsForCatalog)).
+		Watches(&source.Kind{Type: &extensionv1.ArgoCDExtension{}}, handler.EnqueueRequestsFromMapFunc(r.dependentExtensions), dependencyOptions...).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.extensionsForConfigMap))
+	if r.Namespaces.Enabled() {
+		b = b.Watches(&source.Kind{Type: &corev1.Namespace{}}, handler.EnqueueRequestsFromMapFunc(r.extensionsInNamespace))
+	}
+	return b.Complete(r)
 }
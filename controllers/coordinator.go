@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// publishedCondition returns the Ready condition of the coordinator once it published the artifact of the current spec
+func publishedCondition(ext *extensionv1.ArgoCDExtension) metav1.Condition {
+	return metav1.Condition{
+		Type:               extensionv1.ConditionReady,
+		Status:             metav1.ConditionTrue,
+		Reason:             extensionv1.ReasonProcessed,
+		Message:            fmt.Sprintf("Published artifact %s", ext.Status.Artifact.Digest),
+		ObservedGeneration: ext.Generation,
+	}
+}
+
+// reconcileReplicas aggregates the states reported by the agents into the Ready condition without resolving the
+// extension sources again. Extensions the coordinator failed to process or has not processed yet are left as is.
+func (r *ArgoCDExtensionReconciler) reconcileReplicas(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var original extensionv1.ArgoCDExtension
+	if err := r.Get(ctx, req.NamespacedName, &original); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	ext := original.DeepCopy()
+	if ext.DeletionTimestamp != nil || ext.Status.Artifact == nil {
+		return ctrl.Result{}, nil
+	}
+	c := meta.FindStatusCondition(ext.Status.Conditions, extensionv1.ConditionReady)
+	if c == nil || c.ObservedGeneration != ext.Generation ||
+		c.Reason != extensionv1.ReasonProcessed && c.Reason != extensionv1.ReasonReplicasNotReady {
+		return ctrl.Result{}, nil
+	}
+	readyCondition, err := r.reportReplica(ctx, ext, publishedCondition(ext), ext.Status.Artifact.Revisions)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	meta.SetStatusCondition(&ext.Status.Conditions, readyCondition)
+	if !reflect.DeepEqual(ext.Status, original.Status) {
+		return ctrl.Result{}, r.Client.Patch(ctx, ext, r.statusPatch(&original))
+	}
+	return ctrl.Result{}, nil
+}
+
+// specChangedPredicate passes changes of the extension spec and updates of deleted extensions, so the status patches
+// of the agents don't resolve the extension sources again
+func specChangedPredicate() predicate.Predicate {
+	return predicate.Or(predicate.GenerationChangedPredicate{}, predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		return obj.GetDeletionTimestamp() != nil
+	}))
+}
+
+// readyChangedPredicate passes changes of the spec or Ready condition of dependencies
+func readyChangedPredicate() predicate.Predicate {
+	return predicate.Or(specChangedPredicate(), predicate.Funcs{
+		UpdateFunc: func(e event.UpdateEvent) bool {
+			oldExt, ok := e.ObjectOld.(*extensionv1.ArgoCDExtension)
+			newExt, ok2 := e.ObjectNew.(*extensionv1.ArgoCDExtension)
+			if !ok || !ok2 {
+				return true
+			}
+			return !reflect.DeepEqual(meta.FindStatusCondition(oldExt.Status.Conditions, extensionv1.ConditionReady),
+				meta.FindStatusCondition(newExt.Status.Conditions, extensionv1.ConditionReady))
+		},
+	})
+}
+
+// replicasChangedPredicate passes the updates of the replicas reported by the agents
+var replicasChangedPredicate = predicate.Funcs{
+	CreateFunc:  func(event.CreateEvent) bool { return false },
+	DeleteFunc:  func(event.DeleteEvent) bool { return false },
+	GenericFunc: func(event.GenericEvent) bool { return false },
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		oldExt, ok := e.ObjectOld.(*extensionv1.ArgoCDExtension)
+		newExt, ok2 := e.ObjectNew.(*extensionv1.ArgoCDExtension)
+		return ok && ok2 && !reflect.DeepEqual(oldExt.Status.Replicas, newExt.Status.Replicas)
+	},
+}
@@ -0,0 +1,180 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+var (
+	// errDependenciesNotReady is returned when at least one dependency can't be used yet
+	errDependenciesNotReady = errors.New("dependencies are not ready")
+	// errDependencyCycle is returned when the extension transitively depends on itself
+	errDependencyCycle = errors.New("dependency cycle detected")
+)
+
+// checkDependencies returns an error wrapping errDependencyCycle if the extension transitively depends on itself or
+// errDependenciesNotReady if any dependency is missing, is not Ready or does not satisfy the version constraint
+func (r *ArgoCDExtensionReconciler) checkDependencies(ctx context.Context, ext *extensionv1.ArgoCDExtension) error {
+	if len(ext.Spec.DependsOn) == 0 {
+		return nil
+	}
+	var list extensionv1.ArgoCDExtensionList
+	if err := r.List(ctx, &list, client.InNamespace(ext.Namespace)); err != nil {
+		return err
+	}
+	byName := map[string]*extensionv1.ArgoCDExtension{}
+	for i := range list.Items {
+		byName[list.Items[i].Name] = &list.Items[i]
+	}
+	byName[ext.Name] = ext
+	return dependenciesError(ext, byName)
+}
+
+// dependenciesError checks the dependencies of the extension among the extensions of its namespace, by name, like
+// checkDependencies
+func dependenciesError(ext *extensionv1.ArgoCDExtension, byName map[string]*extensionv1.ArgoCDExtension) error {
+	if len(ext.Spec.DependsOn) == 0 {
+		return nil
+	}
+	if cycle := findCycle(ext.Name, byName); cycle != nil {
+		return fmt.Errorf("%w: %s", errDependencyCycle, strings.Join(cycle, " -> "))
+	}
+
+	var problems []string
+	for _, dep := range ext.Spec.DependsOn {
+		d, ok := byName[dep.Name]
+		if !ok {
+			problems = append(problems, fmt.Sprintf("%s does not exist", dep.Name))
+			continue
+		}
+		if d.DeletionTimestamp != nil {
+			problems = append(problems, fmt.Sprintf("%s is being deleted", dep.Name))
+			continue
+		}
+		if !meta.IsStatusConditionTrue(d.Status.Conditions, extensionv1.ConditionReady) {
+			problems = append(problems, fmt.Sprintf("%s is not Ready", dep.Name))
+			continue
+		}
+		if dep.Version == "" {
+			continue
+		}
+		constraints, err := version.NewConstraint(dep.Version)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s version constraint %s is invalid: %v", dep.Name, dep.Version, err))
+			continue
+		}
+		if d.Status.Extension == nil {
+			problems = append(problems, fmt.Sprintf("%s does not declare a version in its manifest", dep.Name))
+			continue
+		}
+		v, err := version.NewVersion(d.Status.Extension.Version)
+		if err != nil || !constraints.Check(v) {
+			problems = append(problems, fmt.Sprintf("%s version %s does not satisfy %s", dep.Name, d.Status.Extension.Version, dep.Version))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%w: %s", errDependenciesNotReady, strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// findCycle returns the dependency path leading from the start extension back to itself, or nil if there is none
+func findCycle(start string, byName map[string]*extensionv1.ArgoCDExtension) []string {
+	visited := map[string]bool{}
+	var path []string
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		path = append(path, name)
+		defer func() {
+			path = path[:len(path)-1]
+		}()
+		ext, ok := byName[name]
+		if !ok {
+			return nil
+		}
+		for _, dep := range ext.Spec.DependsOn {
+			if dep.Name == start {
+				return append(append([]string{}, path...), start)
+			}
+			if visited[dep.Name] {
+				continue
+			}
+			visited[dep.Name] = true
+			if cycle := visit(dep.Name); cycle != nil {
+				return cycle
+			}
+		}
+		return nil
+	}
+	return visit(start)
+}
+
+// listDependents returns the names of the extensions that depend on the named extension and are not being deleted
+func listDependents(ctx context.Context, c client.Client, namespace string, name string) ([]string, error) {
+	var list extensionv1.ArgoCDExtensionList
+	if err := c.List(ctx, &list, client.InNamespace(namespace)); err != nil {
+		return nil, err
+	}
+	var res []string
+	for _, ext := range list.Items {
+		if ext.DeletionTimestamp != nil {
+			continue
+		}
+		for _, dep := range ext.Spec.DependsOn {
+			if dep.Name == name {
+				res = append(res, ext.Name)
+				break
+			}
+		}
+	}
+	sort.Strings(res)
+	return res, nil
+}
+
+// dependentExtensions returns the extensions that depend on the changed extension
+func (r *ArgoCDExtensionReconciler) dependentExtensions(obj client.Object) []reconcile.Request {
+	dependents, err := listDependents(context.Background(), r.Client, obj.GetNamespace(), obj.GetName())
+	if err != nil {
+		ctrl.Log.Error(err, "Failed to list dependent extensions", "extension", obj.GetName())
+		return nil
+	}
+	var res []reconcile.Request
+	for _, name := range dependents {
+		res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: obj.GetNamespace(), Name: name}})
+	}
+	return res
+}
+
+// DeletionWarningWebhook admits ArgoCDExtension deletion requests and warns about extensions that still depend on
+// the deleted extension
+type DeletionWarningWebhook struct {
+	Client client.Client
+}
+
+// Handle returns an allowed response with a warning if other extensions depend on the deleted extension
+func (w *DeletionWarningWebhook) Handle(ctx context.Context, req admission.Request) admission.Response {
+	dependents, err := listDependents(ctx, w.Client, req.Namespace, req.Name)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	res := admission.Allowed("")
+	if len(dependents) > 0 {
+		res = res.WithWarnings(fmt.Sprintf("extensions %s depend on %s and will not be updated until the dependency is restored",
+			strings.Join(dependents, ", "), req.Name))
+	}
+	return res
+}
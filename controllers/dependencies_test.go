@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func newDependentExtension(name string, dependsOn ...string) *extensionv1.ArgoCDExtension {
+	ext := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: name}}
+	for _, dep := range dependsOn {
+		ext.Spec.DependsOn = append(ext.Spec.DependsOn, extensionv1.ExtensionDependency{Name: dep})
+	}
+	return ext
+}
+
+func TestFindCycle(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		extensions []*extensionv1.ArgoCDExtension
+		want       []string
+	}{
+		{name: "no dependencies", extensions: []*extensionv1.ArgoCDExtension{newDependentExtension("a")}},
+		{name: "chain", extensions: []*extensionv1.ArgoCDExtension{
+			newDependentExtension("a", "b"), newDependentExtension("b", "c"), newDependentExtension("c"),
+		}},
+		{name: "diamond", extensions: []*extensionv1.ArgoCDExtension{
+			newDependentExtension("a", "b", "c"), newDependentExtension("b", "d"), newDependentExtension("c", "d"), newDependentExtension("d"),
+		}},
+		{name: "missing dependency", extensions: []*extensionv1.ArgoCDExtension{newDependentExtension("a", "missing")}},
+		{name: "self", extensions: []*extensionv1.ArgoCDExtension{newDependentExtension("a", "a")}, want: []string{"a", "a"}},
+		{name: "direct", extensions: []*extensionv1.ArgoCDExtension{
+			newDependentExtension("a", "b"), newDependentExtension("b", "a"),
+		}, want: []string{"a", "b", "a"}},
+		{name: "transitive", extensions: []*extensionv1.ArgoCDExtension{
+			newDependentExtension("a", "b"), newDependentExtension("b", "c"), newDependentExtension("c", "a"),
+		}, want: []string{"a", "b", "c", "a"}},
+		{name: "cycle not involving start", extensions: []*extensionv1.ArgoCDExtension{
+			newDependentExtension("a", "b"), newDependentExtension("b", "c"), newDependentExtension("c", "b"),
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			byName := map[string]*extensionv1.ArgoCDExtension{}
+			for _, ext := range tc.extensions {
+				byName[ext.Name] = ext
+			}
+			if got := findCycle("a", byName); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("findCycle() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheckDependencies(t *testing.T) {
+	ready := func(ext *extensionv1.ArgoCDExtension, version string) *extensionv1.ArgoCDExtension {
+		ext.Status.Conditions = []metav1.Condition{{Type: extensionv1.ConditionReady, Status: metav1.ConditionTrue, Reason: extensionv1.ReasonProcessed}}
+		if version != "" {
+			ext.Status.Extension = &extensionv1.ExtensionMetadata{Version: version}
+		}
+		return ext
+	}
+	withVersion := func(ext *extensionv1.ArgoCDExtension, constraint string) *extensionv1.ArgoCDExtension {
+		ext.Spec.DependsOn[0].Version = constraint
+		return ext
+	}
+	for _, tc := range []struct {
+		name    string
+		ext     *extensionv1.ArgoCDExtension
+		others  []*extensionv1.ArgoCDExtension
+		wantErr error
+	}{
+		{name: "no dependencies", ext: newDependentExtension("a")},
+		{name: "ready", ext: newDependentExtension("a", "b"), others: []*extensionv1.ArgoCDExtension{ready(newDependentExtension("b"), "")}},
+		{name: "missing", ext: newDependentExtension("a", "b"), wantErr: errDependenciesNotReady},
+		{name: "not ready", ext: newDependentExtension("a", "b"), others: []*extensionv1.ArgoCDExtension{newDependentExtension("b")}, wantErr: errDependenciesNotReady},
+		{name: "version satisfied", ext: withVersion(newDependentExtension("a", "b"), ">= 1.2, < 2.0"),
+			others: []*extensionv1.ArgoCDExtension{ready(newDependentExtension("b"), "1.4.0")}},
+		{name: "version not satisfied", ext: withVersion(newDependentExtension("a", "b"), ">= 2.0"),
+			others: []*extensionv1.ArgoCDExtension{ready(newDependentExtension("b"), "1.4.0")}, wantErr: errDependenciesNotReady},
+		{name: "version not declared", ext: withVersion(newDependentExtension("a", "b"), ">= 1.0"),
+			others: []*extensionv1.ArgoCDExtension{ready(newDependentExtension("b"), "")}, wantErr: errDependenciesNotReady},
+		{name: "cycle", ext: newDependentExtension("a", "b"),
+			others: []*extensionv1.ArgoCDExtension{ready(newDependentExtension("b", "a"), "")}, wantErr: errDependencyCycle},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			scheme := runtime.NewScheme()
+			if err := extensionv1.AddToScheme(scheme); err != nil {
+				t.Fatal(err)
+			}
+			builder := fake.NewClientBuilder().WithScheme(scheme)
+			for _, other := range tc.others {
+				builder = builder.WithObjects(other)
+			}
+			r := &ArgoCDExtensionReconciler{Client: builder.Build()}
+			if err := r.checkDependencies(context.Background(), tc.ext); !errors.Is(err, tc.wantErr) {
+				t.Errorf("checkDependencies() = %v, want %v", err, tc.wantErr)
+			}
+		})
+	}
+}
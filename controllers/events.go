@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"errors"
+
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+// Event reasons emitted for ArgoCDExtension objects
+const (
+	eventReasonSourcesChanged   = "SourcesChanged"
+	eventReasonInstalled        = "Installed"
+	eventReasonDownloadFailed   = "DownloadFailed"
+	eventReasonValidationFailed = "ValidationFailed"
+	eventReasonDependentsExist  = "DependentsExist"
+	eventReasonCleanedUp        = "CleanedUp"
+	eventReasonCleanupFailed    = "CleanupFailed"
+)
+
+// failureEventReason returns the reason of the Warning event emitted for the installation error. Errors that are not
+// download or validation failures use the reason of the Ready condition.
+func failureEventReason(err error, conditionReason string) string {
+	switch {
+	case errors.Is(err, extension.ErrDownloadFailed):
+		return eventReasonDownloadFailed
+	case errors.Is(err, extension.ErrInvalidBundle):
+		return eventReasonValidationFailed
+	}
+	return conditionReason
+}
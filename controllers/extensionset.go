@@ -0,0 +1,234 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/config"
+	"github.com/argoproj/argocd-extensions/pkg/generators"
+)
+
+const (
+	// extensionSetLabel holds the name of the ArgoCDExtensionSet that generated the ArgoCDExtension
+	extensionSetLabel = "argocd-extensions.argoproj.io/extension-set"
+	// templateMetadataAnnotation records the labels and annotations of a generated ArgoCDExtension set by the template
+	templateMetadataAnnotation = "argocd-extensions.argoproj.io/template-metadata"
+
+	// defaultExtensionSetRefreshInterval is the delay before the Git generators of a set are evaluated again if the
+	// refresh interval of the controller configuration is not set
+	defaultExtensionSetRefreshInterval = 3 * time.Minute
+)
+
+// ArgoCDExtensionSetReconciler reconciles a ArgoCDExtensionSet object
+type ArgoCDExtensionSetReconciler struct {
+	client.Client
+	Scheme    *runtime.Scheme
+	Generator *generators.Generator
+	// Settings holds the interval of evaluating Git generators again and the timeouts of reading their repositories
+	Settings *config.Store
+	// Namespaces restricts the reconciled objects to the namespaces matching a label selector
+	Namespaces *NamespaceFilter
+}
+
+func (r *ArgoCDExtensionSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	var original extensionv1.ArgoCDExtensionSet
+	if err := r.Get(ctx, req.NamespacedName, &original); err != nil {
+		return ctrl.Result{}, client.IgnoreNotFound(err)
+	}
+	// generated extensions are garbage collected using owner references
+	if original.DeletionTimestamp != nil {
+		return ctrl.Result{}, nil
+	}
+	set := original.DeepCopy()
+
+	var result ctrl.Result
+	for _, g := range set.Spec.Generators {
+		if generators.IsGit(g) {
+			result.RequeueAfter = r.Settings.Get().RefreshInterval.Duration
+			if result.RequeueAfter == 0 {
+				result.RequeueAfter = defaultExtensionSetRefreshInterval
+			}
+		}
+	}
+
+	readyCondition := metav1.Condition{Type: extensionv1.ConditionReady, ObservedGeneration: set.Generation}
+	desired, err := r.generate(ctx, set)
+	if err != nil {
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = extensionv1.ReasonGenerationFailed
+		readyCondition.Message = err.Error()
+	} else if err := r.sync(ctx, set, desired); err != nil {
+		readyCondition.Status = metav1.ConditionFalse
+		readyCondition.Reason = extensionv1.ReasonProcessingFailed
+		readyCondition.Message = err.Error()
+	} else {
+		readyCondition.Status = metav1.ConditionTrue
+		readyCondition.Reason = extensionv1.ReasonGenerated
+		readyCondition.Message = fmt.Sprintf("Successfully generated %d extensions", len(desired))
+		set.Status.Extensions = nil
+		for _, ext := range desired {
+			set.Status.Extensions = append(set.Status.Extensions, ext.Name)
+		}
+	}
+	meta.SetStatusCondition(&set.Status.Conditions, readyCondition)
+	if !reflect.DeepEqual(set.Status, original.Status) {
+		err := r.Client.Patch(ctx, set, client.MergeFrom(&original))
+		return result, err
+	}
+	return result, nil
+}
+
+// generate renders the template with the parameter sets of all generators
+func (r *ArgoCDExtensionSetReconciler) generate(ctx context.Context, set *extensionv1.ArgoCDExtensionSet) ([]*extensionv1.ArgoCDExtension, error) {
+	timeouts := r.Settings.Get().Timeouts
+	opts := generators.Options{ResolveTimeout: timeouts.Resolve.Duration, DownloadTimeout: timeouts.Download.Duration}
+	var res []*extensionv1.ArgoCDExtension
+	names := map[string]bool{}
+	for i, g := range set.Spec.Generators {
+		paramSets, err := r.Generator.Generate(ctx, g, opts)
+		if err != nil {
+			return nil, fmt.Errorf("generator #%d failed: %v", i, err)
+		}
+		for _, params := range paramSets {
+			ext, err := generators.Render(set.Spec.Template, params)
+			if err != nil {
+				return nil, fmt.Errorf("generator #%d: failed to render template: %v", i, err)
+			}
+			if ext.Name == "" {
+				return nil, fmt.Errorf("generator #%d: template rendered an empty name", i)
+			}
+			if names[ext.Name] {
+				return nil, fmt.Errorf("extension %s is generated more than once", ext.Name)
+			}
+			names[ext.Name] = true
+			res = append(res, ext)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// sync creates or updates the desired extensions and deletes the extensions previously generated by the set that
+// are no longer desired
+func (r *ArgoCDExtensionSetReconciler) sync(ctx context.Context, set *extensionv1.ArgoCDExtensionSet, desired []*extensionv1.ArgoCDExtension) error {
+	names := map[string]bool{}
+	for _, d := range desired {
+		names[d.Name] = true
+		ext := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: set.Namespace, Name: d.Name}}
+		if _, err := controllerutil.CreateOrUpdate(ctx, r.Client, ext, func() error {
+			if !ext.CreationTimestamp.IsZero() && !metav1.IsControlledBy(ext, set) {
+				return fmt.Errorf("extension %s already exists and is not managed by the set", ext.Name)
+			}
+			if err := applyTemplateMetadata(ext, d); err != nil {
+				return err
+			}
+			ext.Labels[extensionSetLabel] = set.Name
+			ext.Spec = d.Spec
+			if ext.DeletionTimestamp == nil && findIndex(ext.Finalizers, finalizerName) == -1 {
+				ext.Finalizers = append(ext.Finalizers, finalizerName)
+			}
+			return controllerutil.SetControllerReference(set, ext, r.Scheme)
+		}); err != nil {
+			return fmt.Errorf("failed to update extension %s: %v", d.Name, err)
+		}
+	}
+
+	var existing extensionv1.ArgoCDExtensionList
+	if err := r.List(ctx, &existing, client.InNamespace(set.Namespace), client.MatchingLabels{extensionSetLabel: set.Name}); err != nil {
+		return err
+	}
+	for i := range existing.Items {
+		ext := &existing.Items[i]
+		if names[ext.Name] || !metav1.IsControlledBy(ext, set) || ext.DeletionTimestamp != nil {
+			continue
+		}
+		if err := r.Delete(ctx, ext); client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("failed to delete extension %s: %v", ext.Name, err)
+		}
+	}
+	return nil
+}
+
+// templateMetadata holds the label and annotation keys of a generated extension that are set by the template
+type templateMetadata struct {
+	Labels      []string `json:"labels,omitempty"`
+	Annotations []string `json:"annotations,omitempty"`
+}
+
+// applyTemplateMetadata sets the labels and annotations of the rendered extension and removes the ones set by a
+// previous version of the template, keeping labels and annotations added by other tools
+func applyTemplateMetadata(ext *extensionv1.ArgoCDExtension, rendered *extensionv1.ArgoCDExtension) error {
+	var previous templateMetadata
+	if data, ok := ext.Annotations[templateMetadataAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &previous); err != nil {
+			return fmt.Errorf("failed to parse %s annotation of %s: %v", templateMetadataAnnotation, ext.Name, err)
+		}
+	}
+	for _, k := range previous.Labels {
+		if _, ok := rendered.Labels[k]; !ok && k != extensionSetLabel {
+			delete(ext.Labels, k)
+		}
+	}
+	for _, k := range previous.Annotations {
+		if _, ok := rendered.Annotations[k]; !ok {
+			delete(ext.Annotations, k)
+		}
+	}
+
+	var managed templateMetadata
+	if ext.Labels == nil {
+		ext.Labels = map[string]string{}
+	}
+	for k, v := range rendered.Labels {
+		ext.Labels[k] = v
+		managed.Labels = append(managed.Labels, k)
+	}
+	if ext.Annotations == nil {
+		ext.Annotations = map[string]string{}
+	}
+	for k, v := range rendered.Annotations {
+		if k == templateMetadataAnnotation {
+			continue
+		}
+		ext.Annotations[k] = v
+		managed.Annotations = append(managed.Annotations, k)
+	}
+	sort.Strings(managed.Labels)
+	sort.Strings(managed.Annotations)
+
+	delete(ext.Annotations, templateMetadataAnnotation)
+	if len(managed.Labels) > 0 || len(managed.Annotations) > 0 {
+		data, err := json.Marshal(managed)
+		if err != nil {
+			return err
+		}
+		ext.Annotations[templateMetadataAnnotation] = string(data)
+	}
+	if len(ext.Annotations) == 0 {
+		ext.Annotations = nil
+	}
+	return nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ArgoCDExtensionSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		WithEventFilter(r.Namespaces.Predicate()).
+		For(&extensionv1.ArgoCDExtensionSet{}).
+		Owns(&extensionv1.ArgoCDExtension{}).
+		Complete(r)
+}
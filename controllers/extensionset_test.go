@@ -0,0 +1,70 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func TestApplyTemplateMetadata(t *testing.T) {
+	newExtension := func(labels, annotations map[string]string) *extensionv1.ArgoCDExtension {
+		return &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Name: "metrics", Labels: labels, Annotations: annotations}}
+	}
+	for _, tc := range []struct {
+		name            string
+		ext             *extensionv1.ArgoCDExtension
+		rendered        *extensionv1.ArgoCDExtension
+		wantLabels      map[string]string
+		wantAnnotations map[string]string
+		wantErr         bool
+	}{
+		{
+			name:            "new extension",
+			ext:             newExtension(nil, nil),
+			rendered:        newExtension(map[string]string{"team": "platform"}, map[string]string{"owner": "ops"}),
+			wantLabels:      map[string]string{"team": "platform"},
+			wantAnnotations: map[string]string{"owner": "ops", templateMetadataAnnotation: `{"labels":["team"],"annotations":["owner"]}`},
+		},
+		{
+			name: "prune removed keys",
+			ext: newExtension(map[string]string{"team": "platform", "tier": "1", extensionSetLabel: "set"},
+				map[string]string{"owner": "ops", templateMetadataAnnotation: `{"labels":["team","tier"],"annotations":["owner"]}`}),
+			rendered:        newExtension(map[string]string{"team": "observability"}, nil),
+			wantLabels:      map[string]string{"team": "observability", extensionSetLabel: "set"},
+			wantAnnotations: map[string]string{templateMetadataAnnotation: `{"labels":["team"]}`},
+		},
+		{
+			name: "keep keys set by other tools",
+			ext: newExtension(map[string]string{"team": "platform", "added": "manually"},
+				map[string]string{"note": "manual", templateMetadataAnnotation: `{"labels":["team"]}`}),
+			rendered:        newExtension(nil, nil),
+			wantLabels:      map[string]string{"added": "manually"},
+			wantAnnotations: map[string]string{"note": "manual"},
+		},
+		{
+			name:     "invalid annotation",
+			ext:      newExtension(nil, map[string]string{templateMetadataAnnotation: "{"}),
+			rendered: newExtension(nil, nil),
+			wantErr:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := applyTemplateMetadata(tc.ext, tc.rendered)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("applyTemplateMetadata() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(tc.ext.Labels, tc.wantLabels) {
+				t.Errorf("labels = %v, want %v", tc.ext.Labels, tc.wantLabels)
+			}
+			if !reflect.DeepEqual(tc.ext.Annotations, tc.wantAnnotations) {
+				t.Errorf("annotations = %v, want %v", tc.ext.Annotations, tc.wantAnnotations)
+			}
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+// Environment variables configuring the argocd-extension-installer init container
+const (
+	// InstallerURLEnv is the URL of the extension archive
+	InstallerURLEnv = "EXTENSION_URL"
+	// installerNameEnv is the extension name
+	installerNameEnv = "EXTENSION_NAME"
+	// installerVersionEnv is the extension version, a new version installs the extension again
+	installerVersionEnv = "EXTENSION_VERSION"
+	// installerJSVarsEnv is a JSON object exposed to the UI extension in a generated JavaScript file
+	installerJSVarsEnv = "EXTENSION_JS_VARS"
+	// installerChecksumURLEnv is the URL of a checksums file listing the archive, e.g. in the sha256sum format
+	installerChecksumURLEnv = "EXTENSION_CHECKSUM_URL"
+	// installerEnabledEnv disables installing the extension if false
+	installerEnabledEnv = "EXTENSION_ENABLED"
+
+	// installerVersionAnnotation holds the EXTENSION_VERSION of extensions configured by the installer environment
+	installerVersionAnnotation = "argocd-extensions.argoproj.io/installer-version"
+	// installerJSVarsAnnotation holds the EXTENSION_JS_VARS of extensions configured by the installer environment
+	installerJSVarsAnnotation = "argocd-extensions.argoproj.io/installer-js-vars"
+)
+
+// InstallerExtensions translates the argocd-extension-installer environment into an in-memory extension with a
+// single web source. Returns false if EXTENSION_URL is not set, and no extension if EXTENSION_ENABLED is false.
+func InstallerExtensions(getenv func(string) string, namespace string) ([]extensionv1.ArgoCDExtension, bool, error) {
+	url := getenv(InstallerURLEnv)
+	if url == "" {
+		return nil, false, nil
+	}
+	if enabled := getenv(installerEnabledEnv); enabled != "" {
+		if ok, err := strconv.ParseBool(enabled); err != nil {
+			return nil, true, fmt.Errorf("invalid %s value %q: %v", installerEnabledEnv, enabled, err)
+		} else if !ok {
+			return nil, true, nil
+		}
+	}
+	name := getenv(installerNameEnv)
+	if name == "" {
+		return nil, true, fmt.Errorf("%s must be set along with %s", installerNameEnv, InstallerURLEnv)
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return nil, true, fmt.Errorf("invalid %s value %q: %v", installerNameEnv, name, errs)
+	}
+
+	ext := extensionv1.ArgoCDExtension{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: extensionv1.ArgoCDExtensionSpec{
+			Sources: []extensionv1.ExtensionSource{{
+				Type: extensionv1.SourceTypeWeb,
+				Web:  &extensionv1.WebSource{Url: url},
+			}},
+		},
+	}
+	if checksumURL := getenv(installerChecksumURLEnv); checksumURL != "" {
+		// go-getter finds the checksum of the archive file name in the checksums file
+		ext.Spec.Sources[0].Web.Checksum = "file:" + checksumURL
+	}
+	ext.Annotations = map[string]string{}
+	if version := getenv(installerVersionEnv); version != "" {
+		ext.Annotations[installerVersionAnnotation] = version
+	}
+	if vars := getenv(installerJSVarsEnv); vars != "" {
+		var values map[string]interface{}
+		if err := json.Unmarshal([]byte(vars), &values); err != nil {
+			return nil, true, fmt.Errorf("invalid %s value, a JSON object is expected: %v", installerJSVarsEnv, err)
+		}
+		ext.Annotations[installerJSVarsAnnotation] = vars
+	}
+	return []extensionv1.ArgoCDExtension{ext}, true, nil
+}
+
+// installerOptions sets the version and the variables file of extensions configured by the installer environment. The
+// variables are assigned to window['<name>_VARS'] in resources/<name>/extension-<name>-vars.js, as the installer does.
+func installerOptions(ext *extensionv1.ArgoCDExtension, options *extension.Options) error {
+	options.Version = ext.Annotations[installerVersionAnnotation]
+	vars, ok := ext.Annotations[installerJSVarsAnnotation]
+	if !ok {
+		return nil
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal([]byte(vars), &values); err != nil {
+		return fmt.Errorf("invalid %s annotation: %v", installerJSVarsAnnotation, err)
+	}
+	data, err := json.Marshal(values)
+	if err != nil {
+		return err
+	}
+	script := fmt.Sprintf("((window) => {\n  window[%q] = %s;\n})(window);\n", ext.Name+"_VARS", data)
+	options.Files = map[string][]byte{
+		fmt.Sprintf("resources/%s/extension-%s-vars.js", ext.Name, ext.Name): []byte(script),
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"testing"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+func TestInstallerExtensions(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		env      map[string]string
+		wantOK   bool
+		wantErr  bool
+		want     int
+		checksum string
+		version  string
+	}{
+		{name: "not configured", env: map[string]string{}},
+		{name: "minimal", env: map[string]string{"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_NAME": "metrics"}, wantOK: true, want: 1},
+		{name: "checksum and version", env: map[string]string{
+			"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_NAME": "metrics",
+			"EXTENSION_CHECKSUM_URL": "https://example.com/sums.txt", "EXTENSION_VERSION": "v1.2.0",
+		}, wantOK: true, want: 1, checksum: "file:https://example.com/sums.txt", version: "v1.2.0"},
+		{name: "disabled", env: map[string]string{"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_ENABLED": "false"}, wantOK: true},
+		{name: "invalid enabled", env: map[string]string{"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_ENABLED": "no way"}, wantOK: true, wantErr: true},
+		{name: "missing name", env: map[string]string{"EXTENSION_URL": "https://example.com/ext.tar"}, wantOK: true, wantErr: true},
+		{name: "invalid name", env: map[string]string{"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_NAME": "My Extension"}, wantOK: true, wantErr: true},
+		{name: "js vars", env: map[string]string{
+			"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_NAME": "metrics", "EXTENSION_JS_VARS": `{"url": "https://grafana"}`,
+		}, wantOK: true, want: 1},
+		{name: "invalid js vars", env: map[string]string{
+			"EXTENSION_URL": "https://example.com/ext.tar", "EXTENSION_NAME": "metrics", "EXTENSION_JS_VARS": `url=https://grafana`,
+		}, wantOK: true, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			extensions, ok, err := InstallerExtensions(func(key string) string { return tc.env[key] }, "argocd")
+			if ok != tc.wantOK || (err != nil) != tc.wantErr {
+				t.Fatalf("InstallerExtensions() = %v, %v, want %v, error %v", ok, err, tc.wantOK, tc.wantErr)
+			}
+			if len(extensions) != tc.want {
+				t.Fatalf("InstallerExtensions() returned %d extensions, want %d", len(extensions), tc.want)
+			}
+			if tc.want == 0 {
+				return
+			}
+			ext := extensions[0]
+			if ext.Namespace != "argocd" || ext.Name != tc.env["EXTENSION_NAME"] {
+				t.Errorf("extension is %s/%s", ext.Namespace, ext.Name)
+			}
+			if web := ext.Spec.Sources[0].Web; web.Url != tc.env["EXTENSION_URL"] || web.Checksum != tc.checksum {
+				t.Errorf("web source is %+v", web)
+			}
+			if version := ext.Annotations[installerVersionAnnotation]; version != tc.version {
+				t.Errorf("version is %q, want %q", version, tc.version)
+			}
+		})
+	}
+}
+
+func TestInstallerOptions(t *testing.T) {
+	ext := &extensionv1.ArgoCDExtension{}
+	ext.Name = "metrics"
+	ext.Annotations = map[string]string{
+		installerVersionAnnotation: "v1.2.0",
+		installerJSVarsAnnotation:  `{"url": "https://grafana", "refresh": 30}`,
+	}
+	var options extension.Options
+	if err := installerOptions(ext, &options); err != nil {
+		t.Fatal(err)
+	}
+	if options.Version != "v1.2.0" {
+		t.Errorf("version is %q", options.Version)
+	}
+	want := "((window) => {\n  window[\"metrics_VARS\"] = {\"refresh\":30,\"url\":\"https://grafana\"};\n})(window);\n"
+	if got := string(options.Files["resources/metrics/extension-metrics-vars.js"]); got != want {
+		t.Errorf("vars file is %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/resources"
+)
+
+// applyManifests applies the Kubernetes objects shipped with the extension if enabled, prunes the objects that are
+// no longer desired and returns the condition describing their health. Returns nil condition if no objects are applied.
+func (r *ArgoCDExtensionReconciler) applyManifests(ctx context.Context, ext *extensionv1.ArgoCDExtension, objects []unstructured.Unstructured) (*metav1.Condition, error) {
+	if !ext.Spec.ApplyManifests {
+		objects = nil
+	}
+	owner := types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}
+	inventory, err := resources.NewApplier(r.Client).Apply(ctx, owner, objects, ext.Status.Resources)
+	ext.Status.Resources = inventory
+	if err != nil {
+		return nil, err
+	}
+	if len(inventory) == 0 {
+		return nil, nil
+	}
+	return manifestsCondition(ext.Generation, inventory), nil
+}
+
+// manifestsCondition returns the ManifestsHealthy condition aggregated from the health of the applied objects:
+// degraded objects take precedence over progressing ones
+func manifestsCondition(generation int64, inventory []extensionv1.ResourceStatus) *metav1.Condition {
+	condition := &metav1.Condition{
+		Type:               extensionv1.ConditionManifestsHealthy,
+		Status:             metav1.ConditionTrue,
+		Reason:             extensionv1.ReasonHealthy,
+		Message:            fmt.Sprintf("All %d applied objects are healthy", len(inventory)),
+		ObservedGeneration: generation,
+	}
+	var degraded, progressing []string
+	for _, res := range inventory {
+		switch res.Health {
+		case extensionv1.HealthStatusDegraded:
+			degraded = append(degraded, fmt.Sprintf("%s %s", res.Kind, res.Name))
+		case extensionv1.HealthStatusProgressing:
+			progressing = append(progressing, fmt.Sprintf("%s %s", res.Kind, res.Name))
+		}
+	}
+	switch {
+	case len(degraded) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = extensionv1.ReasonDegraded
+		condition.Message = fmt.Sprintf("Degraded objects: %s", strings.Join(degraded, ", "))
+	case len(progressing) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = extensionv1.ReasonProgressing
+		condition.Message = fmt.Sprintf("Progressing objects: %s", strings.Join(progressing, ", "))
+	}
+	return condition
+}
+
+// deleteManifests deletes the Kubernetes objects applied for the extension
+func (r *ArgoCDExtensionReconciler) deleteManifests(ctx context.Context, ext *extensionv1.ArgoCDExtension) error {
+	owner := types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}
+	if _, err := resources.NewApplier(r.Client).Prune(ctx, owner, ext.Status.Resources); err != nil {
+		return err
+	}
+	return nil
+}
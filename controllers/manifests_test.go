@@ -0,0 +1,38 @@
+package controllers
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func TestManifestsCondition(t *testing.T) {
+	resource := func(kind string, health extensionv1.HealthStatus) extensionv1.ResourceStatus {
+		return extensionv1.ResourceStatus{Kind: kind, Name: "metrics", Health: health}
+	}
+	for _, tc := range []struct {
+		name        string
+		inventory   []extensionv1.ResourceStatus
+		wantStatus  metav1.ConditionStatus
+		wantReason  string
+		wantMessage string
+	}{
+		{name: "healthy", inventory: []extensionv1.ResourceStatus{resource("ConfigMap", extensionv1.HealthStatusHealthy)},
+			wantStatus: metav1.ConditionTrue, wantReason: extensionv1.ReasonHealthy, wantMessage: "All 1 applied objects are healthy"},
+		{name: "progressing", inventory: []extensionv1.ResourceStatus{
+			resource("ConfigMap", extensionv1.HealthStatusHealthy), resource("Deployment", extensionv1.HealthStatusProgressing),
+		}, wantStatus: metav1.ConditionFalse, wantReason: extensionv1.ReasonProgressing, wantMessage: "Progressing objects: Deployment metrics"},
+		{name: "degraded takes precedence", inventory: []extensionv1.ResourceStatus{
+			resource("Deployment", extensionv1.HealthStatusProgressing), resource("Job", extensionv1.HealthStatusDegraded),
+		}, wantStatus: metav1.ConditionFalse, wantReason: extensionv1.ReasonDegraded, wantMessage: "Degraded objects: Job metrics"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := manifestsCondition(3, tc.inventory)
+			if c.Status != tc.wantStatus || c.Reason != tc.wantReason || c.Message != tc.wantMessage || c.ObservedGeneration != 3 {
+				t.Errorf("manifestsCondition() = %+v", c)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// NamespaceFilter restricts the reconciled objects to the namespaces whose labels match the selector
+type NamespaceFilter struct {
+	Client   client.Reader
+	Selector labels.Selector
+}
+
+// Matches returns true if the labels of the namespace match the selector. Cluster-scoped objects, which have no
+// namespace, always match, as does every namespace if the filter is nil.
+func (f *NamespaceFilter) Matches(ctx context.Context, namespace string) (bool, error) {
+	if f == nil || f.Selector == nil || f.Selector.Empty() || namespace == "" {
+		return true, nil
+	}
+	var ns corev1.Namespace
+	if err := f.Client.Get(ctx, types.NamespacedName{Name: namespace}, &ns); err != nil {
+		return false, client.IgnoreNotFound(err)
+	}
+	return f.Selector.Matches(labels.Set(ns.Labels)), nil
+}
+
+// Predicate returns a predicate filtering out the events of objects in namespaces that do not match the selector
+func (f *NamespaceFilter) Predicate() predicate.Predicate {
+	return predicate.NewPredicateFuncs(func(obj client.Object) bool {
+		matches, err := f.Matches(context.Background(), obj.GetNamespace())
+		if err != nil {
+			ctrl.Log.Error(err, "Failed to get namespace", "namespace", obj.GetNamespace())
+		}
+		return matches
+	})
+}
+
+// Enabled returns true if the filter restricts the namespaces, so namespace label changes must be watched
+func (f *NamespaceFilter) Enabled() bool {
+	return f != nil && f.Selector != nil && !f.Selector.Empty()
+}
+
+// extensionsInNamespace returns the extensions in the namespace, so they are installed once the namespace labels
+// match the selector
+func (r *ArgoCDExtensionReconciler) extensionsInNamespace(obj client.Object) []reconcile.Request {
+	var list extensionv1.ArgoCDExtensionList
+	if err := r.List(context.Background(), &list, client.InNamespace(obj.GetName())); err != nil {
+		ctrl.Log.Error(err, "Failed to list extensions in namespace", "namespace", obj.GetName())
+		return nil
+	}
+	var res []reconcile.Request
+	for _, ext := range list.Items {
+		res = append(res, reconcile.Request{NamespacedName: types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}})
+	}
+	return res
+}
+
+// settingsNamespace returns the namespace of the Argo CD ConfigMaps updated for the extension
+func (r *ArgoCDExtensionReconciler) settingsNamespace(ext *extensionv1.ArgoCDExtension) string {
+	if r.ArgoCDNamespace != "" {
+		return r.ArgoCDNamespace
+	}
+	return ext.Namespace
+}
+
+// settingsClient returns the client reading and updating the Argo CD ConfigMaps
+func (r *ArgoCDExtensionReconciler) settingsClient() client.Client {
+	if r.SettingsClient != nil {
+		return r.SettingsClient
+	}
+	return r.Client
+}
+
+// settingsOwner returns the name owning the settings of the extension in the Argo CD ConfigMaps. Extensions of other
+// namespaces are qualified with their namespace to avoid conflicts.
+func (r *ArgoCDExtensionReconciler) settingsOwner(ext *extensionv1.ArgoCDExtension) string {
+	if r.settingsNamespace(ext) == ext.Namespace {
+		return ext.Name
+	}
+	return ext.Namespace + "/" + ext.Name
+}
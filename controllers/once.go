@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/yaml"
+
+	extensionv1alpha1 "github.com/argoproj/argocd-extensions/api/v1alpha1"
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+// errNoAPIAccess is returned when an extension installed from files references objects stored in the API server
+var errNoAPIAccess = errors.New("extension references objects that can't be read without API server access")
+
+// InstallOnce installs the files of the extensions into the extensions directory, as an init container does, and
+// returns an error listing the extensions that failed. Extensions are installed after the extensions they depend on,
+// and fail if a dependency failed, is missing or is part of a cycle. The Argo CD settings, applied objects and the
+// extension status are left as is, so the reconciler needs no Recorder and may have no Client when the extensions are
+// read from files.
+func (r *ArgoCDExtensionReconciler) InstallOnce(ctx context.Context, extensions []extensionv1.ArgoCDExtension) error {
+	log := ctrl.LoggerFrom(ctx)
+	settings := r.Settings.Get()
+	// the status of the copies records the extensions installed so far, the status read from the API server reflects
+	// the installation by other replicas and is reset
+	byNamespace := map[string]map[string]*extensionv1.ArgoCDExtension{}
+	var ordered []*extensionv1.ArgoCDExtension
+	for i := range extensions {
+		ext := extensions[i].DeepCopy()
+		ext.Status = extensionv1.ArgoCDExtensionStatus{}
+		if byNamespace[ext.Namespace] == nil {
+			byNamespace[ext.Namespace] = map[string]*extensionv1.ArgoCDExtension{}
+		}
+		byNamespace[ext.Namespace][ext.Name] = ext
+		ordered = append(ordered, ext)
+	}
+	var failed []string
+	for _, ext := range sortByDependencies(ordered, byNamespace) {
+		err := dependenciesError(ext, byNamespace[ext.Namespace])
+		if err == nil {
+			err = r.installOnce(ctx, ext, settings.Timeouts.Resolve.Duration, settings.Timeouts.Download.Duration)
+		}
+		if err != nil {
+			log.Error(err, "Failed to install extension", "namespace", ext.Namespace, "name", ext.Name)
+			failed = append(failed, fmt.Sprintf("%s/%s", ext.Namespace, ext.Name))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to install %d of %d extensions: %s", len(failed), len(extensions), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// sortByDependencies returns the extensions ordered so that every extension follows the extensions of the same
+// namespace it depends on. The order of the extensions is kept otherwise. Cycles are broken arbitrarily, the
+// extensions that are part of a cycle fail anyway.
+func sortByDependencies(extensions []*extensionv1.ArgoCDExtension,
+	byNamespace map[string]map[string]*extensionv1.ArgoCDExtension) []*extensionv1.ArgoCDExtension {
+	var res []*extensionv1.ArgoCDExtension
+	visited := map[*extensionv1.ArgoCDExtension]bool{}
+	var visit func(ext *extensionv1.ArgoCDExtension)
+	visit = func(ext *extensionv1.ArgoCDExtension) {
+		if visited[ext] {
+			return
+		}
+		visited[ext] = true
+		for _, dep := range ext.Spec.DependsOn {
+			if d, ok := byNamespace[ext.Namespace][dep.Name]; ok {
+				visit(d)
+			}
+		}
+		res = append(res, ext)
+	}
+	for _, ext := range extensions {
+		visit(ext)
+	}
+	return res
+}
+
+// installOnce installs the files of the extension and sets its Ready condition and manifest metadata, so the
+// extensions depending on it can be checked. Extensions targeting other Argo CD instances are skipped and not Ready.
+func (r *ArgoCDExtensionReconciler) installOnce(ctx context.Context, ext *extensionv1.ArgoCDExtension, resolveTimeout, downloadTimeout time.Duration) error {
+	log := ctrl.LoggerFrom(ctx).WithValues("namespace", ext.Namespace, "name", ext.Name)
+	if targeted, err := r.isTargeted(ext); err != nil {
+		return err
+	} else if !targeted {
+		log.Info("Skipping extension targeting other Argo CD instances.")
+		return nil
+	}
+	if r.Client == nil && (ext.Spec.Catalog != nil || ext.Spec.Config != nil && ext.Spec.Config.ConfigMapRef != nil) {
+		return errNoAPIAccess
+	}
+	resolved, err := r.resolveCatalog(ctx, ext)
+	if err != nil {
+		return err
+	}
+	values, err := r.resolveConfig(ctx, ext)
+	if err != nil {
+		return err
+	}
+	options := extension.Options{
+		ArgoCDVersion:   r.ArgoCDVersion,
+		VerifyLua:       r.VerifyLua,
+		Config:          values,
+		ResolveTimeout:  resolveTimeout,
+		DownloadTimeout: downloadTimeout,
+	}
+	if err := installerOptions(ext, &options); err != nil {
+		return err
+	}
+	extensionCtx := extension.NewExtensionContext(resolved, r.ExtensionsPath, options)
+	if err := extensionCtx.Process(ctrl.LoggerInto(ctx, log)); err != nil {
+		return err
+	}
+	meta.SetStatusCondition(&ext.Status.Conditions, metav1.Condition{
+		Type: extensionv1.ConditionReady, Status: metav1.ConditionTrue, Reason: extensionv1.ReasonProcessed,
+	})
+	ext.Status.Extension = toExtensionMetadata(extensionCtx.Manifest())
+	log.Info("Installed extension.", "files", len(extensionCtx.Files()))
+	return nil
+}
+
+// LoadExtensions reads the ArgoCDExtension objects of the YAML files, or of the .yaml, .yml and .json files in the
+// directories, sorted by namespace and name. Other objects are ignored. Extensions without a namespace are placed in
+// the given namespace.
+func LoadExtensions(paths []string, namespace string) ([]extensionv1.ArgoCDExtension, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		entries, err := os.ReadDir(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			switch filepath.Ext(entry.Name()) {
+			case ".yaml", ".yml", ".json":
+				if !entry.IsDir() {
+					files = append(files, filepath.Join(p, entry.Name()))
+				}
+			}
+		}
+	}
+
+	var res []extensionv1.ArgoCDExtension
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		extensions, err := decodeExtensions(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		res = append(res, extensions...)
+	}
+	for i := range res {
+		if res[i].Namespace == "" {
+			res[i].Namespace = namespace
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Namespace != res[j].Namespace {
+			return res[i].Namespace < res[j].Namespace
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res, nil
+}
+
+// decodeExtensions decodes the ArgoCDExtension objects of a multi-document YAML file. v1alpha1 objects are converted.
+func decodeExtensions(data []byte) ([]extensionv1.ArgoCDExtension, error) {
+	reader := utilyaml.NewYAMLReader(bufio.NewReader(bytes.NewReader(data)))
+	var res []extensionv1.ArgoCDExtension
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		var typeMeta metav1.TypeMeta
+		if err := yaml.Unmarshal(doc, &typeMeta); err != nil {
+			return nil, err
+		}
+		if typeMeta.Kind != "ArgoCDExtension" {
+			continue
+		}
+		var ext extensionv1.ArgoCDExtension
+		switch typeMeta.APIVersion {
+		case extensionv1.GroupVersion.String():
+			if err := yaml.UnmarshalStrict(doc, &ext); err != nil {
+				return nil, err
+			}
+		case extensionv1alpha1.GroupVersion.String():
+			var legacy extensionv1alpha1.ArgoCDExtension
+			if err := yaml.UnmarshalStrict(doc, &legacy); err != nil {
+				return nil, err
+			}
+			if err := legacy.ConvertTo(&ext); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, fmt.Errorf("unsupported ArgoCDExtension version %s", typeMeta.APIVersion)
+		}
+		if ext.Name == "" {
+			return nil, errors.New("extension has no name")
+		}
+		res = append(res, ext)
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// newBundle returns a tar archive holding the manifest of a UI extension
+func newBundle(t *testing.T, name string, version string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for path, content := range map[string]string{
+		"extension.yaml": fmt.Sprintf("name: %s\nversion: %s\ntype: UI\n", name, version),
+		"resources/" + name + "/extension-" + name + ".js": "window.extensions = {};",
+	} {
+		if err := w.WriteHeader(&tar.Header{Name: path, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestInstallOnce(t *testing.T) {
+	bundles := map[string][]byte{
+		"/base.tar": newBundle(t, "base", "1.2.0"),
+		"/app.tar":  newBundle(t, "app", "1.0.0"),
+	}
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			mu.Lock()
+			requests = append(requests, r.URL.Path)
+			mu.Unlock()
+		}
+		if bundle, ok := bundles[r.URL.Path]; ok {
+			_, _ = w.Write(bundle)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	withSource := func(ext *extensionv1.ArgoCDExtension, path string) extensionv1.ArgoCDExtension {
+		ext.Spec.Sources = []extensionv1.ExtensionSource{{
+			Type: extensionv1.SourceTypeWeb, Web: &extensionv1.WebSource{Url: server.URL + path},
+		}}
+		return *ext
+	}
+	app := newDependentExtension("app", "base")
+	app.Spec.DependsOn[0].Version = ">= 1.0"
+	tooNew := newDependentExtension("too-new", "base")
+	tooNew.Spec.DependsOn[0].Version = ">= 2.0"
+	extensions := []extensionv1.ArgoCDExtension{
+		withSource(app, "/app.tar"),
+		withSource(newDependentExtension("base"), "/base.tar"),
+		withSource(newDependentExtension("broken"), "/broken.tar"),
+		withSource(newDependentExtension("cycle-a", "cycle-b"), "/cycle-a.tar"),
+		withSource(newDependentExtension("cycle-b", "cycle-a"), "/cycle-b.tar"),
+		withSource(newDependentExtension("needs-broken", "broken"), "/needs-broken.tar"),
+		withSource(newDependentExtension("needs-missing", "missing"), "/needs-missing.tar"),
+		withSource(tooNew, "/too-new.tar"),
+	}
+	// the status read from the API server does not make dependencies ready
+	extensions[2].Status.Conditions = []metav1.Condition{
+		{Type: extensionv1.ConditionReady, Status: metav1.ConditionTrue, Reason: extensionv1.ReasonProcessed},
+	}
+
+	r := &ArgoCDExtensionReconciler{ExtensionsPath: t.TempDir()}
+	err := r.InstallOnce(context.Background(), extensions)
+	want := "failed to install 6 of 8 extensions: argocd/broken, argocd/cycle-b, argocd/cycle-a, argocd/needs-broken, " +
+		"argocd/needs-missing, argocd/too-new"
+	if err == nil || err.Error() != want {
+		t.Errorf("InstallOnce() = %v, want %s", err, want)
+	}
+	// dependencies are installed first and extensions whose dependencies failed are not downloaded
+	if got := strings.Join(requests, ","); got != "/base.tar,/app.tar,/broken.tar" {
+		t.Errorf("requests = %s, want the base, app and broken extensions in order", got)
+	}
+	if len(extensions[1].Status.Conditions) > 0 {
+		t.Error("InstallOnce() changed the status of the given extensions")
+	}
+}
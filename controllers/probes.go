@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+// InstalledCheck returns a readiness check that fails until this replica attempted to install every ArgoCDExtension
+// into the extensions directory. Extensions that failed for the current generation don't hold back the readiness,
+// unless they wait for dependencies that are still pending. Extensions being deleted, in namespaces filtered out or
+// targeting other Argo CD instances are ignored.
+func (r *ArgoCDExtensionReconciler) InstalledCheck() healthz.Checker {
+	return func(req *http.Request) error {
+		var list extensionv1.ArgoCDExtensionList
+		if err := r.List(req.Context(), &list); err != nil {
+			return err
+		}
+		byName := map[types.NamespacedName]*extensionv1.ArgoCDExtension{}
+		for i := range list.Items {
+			ext := &list.Items[i]
+			if ext.DeletionTimestamp != nil {
+				continue
+			}
+			if matches, err := r.Namespaces.Matches(req.Context(), ext.Namespace); err != nil {
+				return err
+			} else if !matches {
+				continue
+			}
+			if targeted, err := r.isTargeted(ext); err != nil || !targeted {
+				continue
+			}
+			byName[types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}] = ext
+		}
+		var missing []string
+		for key, ext := range byName {
+			if r.installPending(ext, byName, map[types.NamespacedName]bool{}) {
+				missing = append(missing, key.Name)
+			}
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return fmt.Errorf("extensions not installed yet: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	}
+}
+
+// failedAttempt is the Ready=False condition recorded by this replica for a generation of an extension
+type failedAttempt struct {
+	generation int64
+	reason     string
+}
+
+// recordAttempt remembers the outcome of installing the extension once its condition has been recorded
+func (r *ArgoCDExtensionReconciler) recordAttempt(ext *extensionv1.ArgoCDExtension, condition metav1.Condition) {
+	key := types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}
+	if condition.Status == metav1.ConditionTrue {
+		r.attempts.Delete(key)
+		return
+	}
+	r.attempts.Store(key, failedAttempt{generation: ext.Generation, reason: condition.Reason})
+}
+
+// installPending returns true if the extension is not installed and this replica either never attempted to install
+// the current generation or is waiting for something that is still in progress: the artifact of the coordinator or
+// dependencies that are themselves pending
+func (r *ArgoCDExtensionReconciler) installPending(ext *extensionv1.ArgoCDExtension, byName map[types.NamespacedName]*extensionv1.ArgoCDExtension, visited map[types.NamespacedName]bool) bool {
+	key := types.NamespacedName{Namespace: ext.Namespace, Name: ext.Name}
+	if visited[key] {
+		return false
+	}
+	visited[key] = true
+	if extension.NewExtensionContext(ext, r.ExtensionsPath, extension.Options{}).Installed() {
+		return false
+	}
+	value, ok := r.attempts.Load(key)
+	if !ok || value.(failedAttempt).generation != ext.Generation {
+		return true
+	}
+	reason := value.(failedAttempt).reason
+	if reason == extensionv1.ReasonArtifactPending {
+		// agents wait until the coordinator either publishes the artifact or reports its own failure
+		c := meta.FindStatusCondition(ext.Status.Conditions, extensionv1.ConditionReady)
+		if c == nil || c.ObservedGeneration != ext.Generation || c.Status != metav1.ConditionFalse || c.Reason == extensionv1.ReasonArtifactPending {
+			return true
+		}
+		reason = c.Reason
+	}
+	if reason != extensionv1.ReasonDependenciesNotReady {
+		return false
+	}
+	for _, dep := range ext.Spec.DependsOn {
+		if d, ok := byName[types.NamespacedName{Namespace: ext.Namespace, Name: dep.Name}]; ok && r.installPending(d, byName, visited) {
+			return true
+		}
+	}
+	return false
+}
+
+// WritableCheck returns a check that fails if a file can't be created in the given directory
+func WritableCheck(dir string) healthz.Checker {
+	return func(_ *http.Request) error {
+		f, err := os.CreateTemp(dir, ".probe-")
+		if err != nil {
+			return fmt.Errorf("%s is not writable: %v", dir, err)
+		}
+		_ = f.Close()
+		return os.Remove(f.Name())
+	}
+}
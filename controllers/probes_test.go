@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func TestInstalledCheck(t *testing.T) {
+	failed := func(reason string) metav1.Condition {
+		return metav1.Condition{Type: extensionv1.ConditionReady, Status: metav1.ConditionFalse, Reason: reason}
+	}
+	withGeneration := func(ext *extensionv1.ArgoCDExtension, generation int64) *extensionv1.ArgoCDExtension {
+		ext.Generation = generation
+		return ext
+	}
+	extensions := []*extensionv1.ArgoCDExtension{
+		newDependentExtension("never-attempted"),
+		withGeneration(newDependentExtension("incompatible"), 1),
+		withGeneration(newDependentExtension("changed-since-failure"), 2),
+		newDependentExtension("installed"),
+		newDependentExtension("depends-on-failed", "incompatible"),
+		newDependentExtension("depends-on-pending", "never-attempted"),
+	}
+	scheme := runtime.NewScheme()
+	if err := extensionv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	builder := fake.NewClientBuilder().WithScheme(scheme)
+	for _, ext := range extensions {
+		builder = builder.WithObjects(ext.DeepCopy())
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".argocd.installed.snapshot"), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r := &ArgoCDExtensionReconciler{Client: builder.Build(), ExtensionsPath: dir}
+	r.recordAttempt(extensions[1], failed(extensionv1.ReasonIncompatible))
+	r.recordAttempt(withGeneration(extensions[2].DeepCopy(), 1), failed(extensionv1.ReasonProcessingFailed))
+	r.recordAttempt(extensions[4], failed(extensionv1.ReasonDependenciesNotReady))
+	r.recordAttempt(extensions[5], failed(extensionv1.ReasonDependenciesNotReady))
+
+	check := r.InstalledCheck()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	want := "extensions not installed yet: changed-since-failure, depends-on-pending, never-attempted"
+	if err := check(req); err == nil || err.Error() != want {
+		t.Errorf("check() = %v, want %s", err, want)
+	}
+
+	// a failed attempt of the dependency releases the extensions waiting for it
+	r.recordAttempt(extensions[0], failed(extensionv1.ReasonProcessingFailed))
+	want = "extensions not installed yet: changed-since-failure"
+	if err := check(req); err == nil || err.Error() != want {
+		t.Errorf("check() = %v, want %s", err, want)
+	}
+
+	r.recordAttempt(extensions[2], failed(extensionv1.ReasonProcessingFailed))
+	if err := check(req); err != nil {
+		t.Errorf("check() = %v, want nil", err)
+	}
+}
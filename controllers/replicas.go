@@ -0,0 +1,178 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// ReplicaIdentity identifies the Argo CD server replica whose extensions sidecar runs the controller
+type ReplicaIdentity struct {
+	// Pod is the name of the replica pod. The coordinator has no pod name, it only aggregates the states reported by
+	// the agents.
+	Pod string
+	// Namespace is the namespace of the replica pods
+	Namespace string
+	// Reader gets pods without caching them
+	Reader client.Reader
+}
+
+// reportReplica records the state of this replica in the extension status, drops the replicas whose pods no longer
+// exist and returns the Ready condition aggregated over the live replicas. Returns the condition of this replica as is
+// if replicas are not reported.
+func (r *ArgoCDExtensionReconciler) reportReplica(ctx context.Context, ext *extensionv1.ArgoCDExtension, own metav1.Condition, revisions []string) (metav1.Condition, error) {
+	if r.Replica == nil {
+		return own, nil
+	}
+	replicas, err := r.liveReplicas(ctx, ext.Status.Replicas)
+	if err != nil {
+		return own, err
+	}
+	revision := strings.Join(revisions, ",")
+	if r.Replica.Pod == "" {
+		ext.Status.Replicas = replicas
+		return aggregateReady(ext, own, replicas, revision), nil
+	}
+	state := extensionv1.ReplicaStatus{
+		Pod:                r.Replica.Pod,
+		Ready:              own.Status,
+		Reason:             own.Reason,
+		Message:            own.Message,
+		ObservedGeneration: ext.Generation,
+		LastTransitionTime: metav1.Now(),
+	}
+	index := findReplica(replicas, r.Replica.Pod)
+	if own.Status == metav1.ConditionTrue {
+		state.Revision = revision
+	} else if index > -1 {
+		// a failed replica keeps serving the previously installed revisions
+		state.Revision = replicas[index].Revision
+	}
+	if index > -1 {
+		if prev := replicas[index]; prev.Ready == state.Ready && prev.Reason == state.Reason && prev.Message == state.Message &&
+			prev.Revision == state.Revision && prev.ObservedGeneration == state.ObservedGeneration {
+			state.LastTransitionTime = prev.LastTransitionTime
+		}
+		replicas[index] = state
+	} else {
+		replicas = append(replicas, state)
+	}
+	sort.Slice(replicas, func(i, j int) bool {
+		return replicas[i].Pod < replicas[j].Pod
+	})
+	ext.Status.Replicas = replicas
+	return aggregateReady(ext, own, replicas, revision), nil
+}
+
+// aggregateReady returns the condition of this replica if it is not Ready, otherwise a condition that is only true
+// if all replicas installed the revisions of the current spec this replica installed
+func aggregateReady(ext *extensionv1.ArgoCDExtension, own metav1.Condition, replicas []extensionv1.ReplicaStatus, revision string) metav1.Condition {
+	if own.Status != metav1.ConditionTrue {
+		return own
+	}
+	var notReady []string
+	for _, replica := range replicas {
+		switch {
+		case replica.ObservedGeneration != ext.Generation:
+			notReady = append(notReady, fmt.Sprintf("%s has not processed the current spec", replica.Pod))
+		case replica.Ready != metav1.ConditionTrue:
+			notReady = append(notReady, fmt.Sprintf("%s: %s", replica.Pod, replica.Message))
+		case replica.Revision != revision:
+			notReady = append(notReady, fmt.Sprintf("%s installed %s instead of %s", replica.Pod, replica.Revision, revision))
+		}
+	}
+	if len(notReady) > 0 {
+		own.Status = metav1.ConditionFalse
+		own.Reason = extensionv1.ReasonReplicasNotReady
+		own.Message = strings.Join(notReady, "; ")
+		return own
+	}
+	own.Message = fmt.Sprintf("%s on %d replicas", own.Message, len(replicas))
+	return own
+}
+
+// releaseReplica removes this replica from the extension status once it cleaned up the extension and returns the
+// number of live replicas that still have to clean up. Returns zero if replicas are not reported.
+func (r *ArgoCDExtensionReconciler) releaseReplica(ctx context.Context, ext *extensionv1.ArgoCDExtension) (int, error) {
+	if r.Replica == nil {
+		return 0, nil
+	}
+	replicas, err := r.liveReplicas(ctx, ext.Status.Replicas)
+	if err != nil {
+		return 0, err
+	}
+	if index := findReplica(replicas, r.Replica.Pod); index > -1 {
+		replicas = append(replicas[:index], replicas[index+1:]...)
+	}
+	ext.Status.Replicas = replicas
+	return len(replicas), nil
+}
+
+// replicaReleased returns true if this replica is not listed in the extension status, because it already cleaned up
+// the deleted extension or because it is the coordinator, and other live replicas still have to clean up
+func (r *ArgoCDExtensionReconciler) replicaReleased(ctx context.Context, ext *extensionv1.ArgoCDExtension) (bool, error) {
+	if r.Replica == nil || findReplica(ext.Status.Replicas, r.Replica.Pod) > -1 {
+		return false, nil
+	}
+	replicas, err := r.liveReplicas(ctx, ext.Status.Replicas)
+	return len(replicas) > 0, err
+}
+
+// previousMessage returns the message of the last reported Ready condition of this replica
+func (r *ArgoCDExtensionReconciler) previousMessage(ext *extensionv1.ArgoCDExtension) (string, bool) {
+	if r.Replica != nil && r.Replica.Pod != "" {
+		if index := findReplica(ext.Status.Replicas, r.Replica.Pod); index > -1 {
+			return ext.Status.Replicas[index].Message, true
+		}
+		return "", false
+	}
+	if c := meta.FindStatusCondition(ext.Status.Conditions, extensionv1.ConditionReady); c != nil {
+		return c.Message, true
+	}
+	return "", false
+}
+
+// liveReplicas returns the replicas whose pods still exist, including this replica
+func (r *ArgoCDExtensionReconciler) liveReplicas(ctx context.Context, replicas []extensionv1.ReplicaStatus) ([]extensionv1.ReplicaStatus, error) {
+	var res []extensionv1.ReplicaStatus
+	for _, replica := range replicas {
+		if replica.Pod != r.Replica.Pod {
+			var pod corev1.Pod
+			if err := r.Replica.Reader.Get(ctx, types.NamespacedName{Namespace: r.Replica.Namespace, Name: replica.Pod}, &pod); apierrors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return nil, fmt.Errorf("failed to get replica pod %s: %v", replica.Pod, err)
+			}
+		}
+		res = append(res, replica)
+	}
+	return res, nil
+}
+
+// statusPatch returns the patch of the extension status. Replicas patch concurrently, so the patch fails if the
+// extension changed since it was read and the reconciliation is retried.
+func (r *ArgoCDExtensionReconciler) statusPatch(original *extensionv1.ArgoCDExtension) client.Patch {
+	if r.Replica != nil {
+		return client.MergeFromWithOptions(original, client.MergeFromWithOptimisticLock{})
+	}
+	return client.MergeFrom(original)
+}
+
+func findReplica(replicas []extensionv1.ReplicaStatus, pod string) int {
+	for i := range replicas {
+		if replicas[i].Pod == pod {
+			return i
+		}
+	}
+	return -1
+}
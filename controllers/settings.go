@@ -0,0 +1,120 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/settings"
+)
+
+// updateSettings merges the settings generated from the installed extension into Argo CD ConfigMaps
+func (r *ArgoCDExtensionReconciler) updateSettings(ctx context.Context, ext *extensionv1.ArgoCDExtension, generated *extension.Settings) error {
+	owner := r.settingsOwner(ext)
+	err := settings.NewManager(r.settingsClient(), r.settingsNamespace(ext)).Update(ctx, settings.ArgoCDConfigMapName, func(cm *corev1.ConfigMap) error {
+		if err := settings.SetOwnedKeys(cm, owner, generated.ResourceCustomizations); err != nil {
+			return err
+		}
+		var proxyExtensions []interface{}
+		for _, p := range generated.ProxyExtensions {
+			proxyExtensions = append(proxyExtensions, p)
+		}
+		if err := settings.SetOwnedEntries(cm, owner, settings.ExtensionConfig, proxyExtensions); err != nil {
+			return err
+		}
+		for setting, links := range map[settings.ListSetting][]extension.DeepLink{
+			settings.ApplicationLinks: generated.Links.Application,
+			settings.ResourceLinks:    generated.Links.Resource,
+			settings.ProjectLinks:     generated.Links.Project,
+		} {
+			var entries []interface{}
+			for _, l := range links {
+				entries = append(entries, l)
+			}
+			if err := settings.SetOwnedEntries(cm, owner, setting, entries); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s: %v", settings.ArgoCDConfigMapName, err)
+	}
+	return nil
+}
+
+// updateRBAC merges the extension RBAC policy into argocd-rbac-cm if enabled and returns the condition describing
+// whether the required permissions are granted. Returns nil condition if the extension requires no permissions.
+func (r *ArgoCDExtensionReconciler) updateRBAC(ctx context.Context, ext *extensionv1.ArgoCDExtension, generated *extension.Settings) (*metav1.Condition, error) {
+	var lines []string
+	if ext.Spec.RBAC != nil && ext.Spec.RBAC.Enabled {
+		lines = append(lines, generated.RBAC.Policies...)
+		lines = append(lines, generated.RBAC.Bindings...)
+		for _, b := range ext.Spec.RBAC.Bindings {
+			line, err := settings.BindingLine(b.Subject, b.Role)
+			if err != nil {
+				return nil, err
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	owner := r.settingsOwner(ext)
+	var missing []string
+	err := settings.NewManager(r.settingsClient(), r.settingsNamespace(ext)).Update(ctx, settings.ArgoCDRBACConfigMapName, func(cm *corev1.ConfigMap) error {
+		if err := settings.SetOwnedBlock(cm, owner, settings.PolicyCSVKey, lines); err != nil {
+			return err
+		}
+		missing = nil
+		for _, line := range generated.RBAC.Policies {
+			if !settings.HasPolicyLine(cm, line) {
+				missing = append(missing, line)
+			}
+		}
+		for _, object := range generated.RequiredPermissions {
+			if !settings.HasPermission(cm, "extensions", "invoke", object) {
+				missing = append(missing, fmt.Sprintf("p, <subject>, extensions, invoke, %s, allow", object))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update %s: %v", settings.ArgoCDRBACConfigMapName, err)
+	}
+
+	if len(generated.RBAC.Policies) == 0 && len(generated.RequiredPermissions) == 0 {
+		return nil, nil
+	}
+	condition := &metav1.Condition{
+		Type:               extensionv1.ConditionRBACConfigured,
+		Status:             metav1.ConditionTrue,
+		Reason:             extensionv1.ReasonPermissionsGranted,
+		Message:            "Argo CD RBAC grants all permissions required by the extension",
+		ObservedGeneration: ext.Generation,
+	}
+	if len(missing) > 0 {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = extensionv1.ReasonPermissionsMissing
+		condition.Message = fmt.Sprintf("%s is missing policies: %s", settings.ArgoCDRBACConfigMapName, strings.Join(missing, "; "))
+	}
+	return condition, nil
+}
+
+// deleteSettings removes all settings owned by the extension from Argo CD ConfigMaps
+func (r *ArgoCDExtensionReconciler) deleteSettings(ctx context.Context, ext *extensionv1.ArgoCDExtension) error {
+	owner := r.settingsOwner(ext)
+	manager := settings.NewManager(r.settingsClient(), r.settingsNamespace(ext))
+	for _, name := range []string{settings.ArgoCDConfigMapName, settings.ArgoCDRBACConfigMapName} {
+		if err := manager.Update(ctx, name, func(cm *corev1.ConfigMap) error {
+			return settings.RemoveOwner(cm, owner)
+		}); err != nil {
+			return fmt.Errorf("failed to update %s: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/settings"
+)
+
+func TestUpdateAndDeleteSettings(t *testing.T) {
+	argocdCM := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: settings.ArgoCDConfigMapName},
+		Data:       map[string]string{"url": "https://argocd"},
+	}
+	rbacCM := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: settings.ArgoCDRBACConfigMapName}}
+	c := fake.NewClientBuilder().WithObjects(argocdCM, rbacCM).Build()
+	r := &ArgoCDExtensionReconciler{Client: c}
+	ext := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: "widgets"}}
+	getData := func() map[string]string {
+		var cm corev1.ConfigMap
+		if err := c.Get(context.Background(), types.NamespacedName{Namespace: "argocd", Name: settings.ArgoCDConfigMapName}, &cm); err != nil {
+			t.Fatal(err)
+		}
+		return cm.Data
+	}
+
+	for _, step := range []struct {
+		name           string
+		customizations map[string]string
+		want           map[string]string
+	}{
+		{
+			name:           "merge",
+			customizations: map[string]string{"resource.customizations.health.example.com_Widget": "hs = {}"},
+			want:           map[string]string{"url": "https://argocd", "resource.customizations.health.example.com_Widget": "hs = {}"},
+		},
+		{
+			name:           "replace",
+			customizations: map[string]string{"resource.customizations.actions.example.com_Widget": "actions = {}"},
+			want:           map[string]string{"url": "https://argocd", "resource.customizations.actions.example.com_Widget": "actions = {}"},
+		},
+	} {
+		generated := &extension.Settings{ResourceCustomizations: step.customizations}
+		if err := r.updateSettings(context.Background(), ext, generated); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if data := getData(); !reflect.DeepEqual(data, step.want) {
+			t.Errorf("%s: data = %v, want %v", step.name, data, step.want)
+		}
+	}
+
+	// another extension can't take over keys it does not own
+	other := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: "other"}}
+	generated := &extension.Settings{ResourceCustomizations: map[string]string{"resource.customizations.actions.example.com_Widget": "-"}}
+	if err := r.updateSettings(context.Background(), other, generated); err == nil {
+		t.Error("updateSettings() of a key owned by another extension succeeded")
+	}
+
+	if err := r.deleteSettings(context.Background(), ext); err != nil {
+		t.Fatal(err)
+	}
+	if data, want := getData(), map[string]string{"url": "https://argocd"}; !reflect.DeepEqual(data, want) {
+		t.Errorf("data after deletion = %v, want %v", data, want)
+	}
+}
+
+func TestUpdateSettingsOfUnwatchedNamespace(t *testing.T) {
+	argocdCM := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: settings.ArgoCDConfigMapName}}
+	rbacCM := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: settings.ArgoCDRBACConfigMapName}}
+	settingsClient := fake.NewClientBuilder().WithObjects(argocdCM, rbacCM).Build()
+	// the client of the watched namespaces does not hold the Argo CD ConfigMaps
+	r := &ArgoCDExtensionReconciler{
+		Client: fake.NewClientBuilder().Build(), SettingsClient: settingsClient, ArgoCDNamespace: "argocd",
+	}
+	ext := &extensionv1.ArgoCDExtension{ObjectMeta: metav1.ObjectMeta{Namespace: "team-a", Name: "widgets"}}
+	generated := &extension.Settings{ResourceCustomizations: map[string]string{"resource.customizations.health.example.com_Widget": "hs = {}"}}
+	if err := r.updateSettings(context.Background(), ext, generated); err != nil {
+		t.Fatal(err)
+	}
+	var cm corev1.ConfigMap
+	if err := settingsClient.Get(context.Background(), client.ObjectKeyFromObject(argocdCM), &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Data["resource.customizations.health.example.com_Widget"] != "hs = {}" {
+		t.Errorf("data = %v, want the extension settings", cm.Data)
+	}
+}
@@ -0,0 +1,48 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+)
+
+// isTargeted returns true if the served Argo CD instance must install the extension, i.e. the extension has no
+// instance selector or the selector matches the instance labels
+func (r *ArgoCDExtensionReconciler) isTargeted(ext *extensionv1.ArgoCDExtension) (bool, error) {
+	if ext.Spec.Target == nil || ext.Spec.Target.InstanceSelector == nil {
+		return true, nil
+	}
+	selector, err := metav1.LabelSelectorAsSelector(ext.Spec.Target.InstanceSelector)
+	if err != nil {
+		return false, fmt.Errorf("invalid instance selector: %v", err)
+	}
+	return selector.Matches(labels.Set(r.InstanceLabels)), nil
+}
+
+// removeUntargeted removes the files and settings installed for the extension before its target changed to other
+// Argo CD instances. The status and finalizer are left to the controllers of the targeted instances. Applied objects
+// are not pruned: they belong to the extension rather than to an instance, and the controllers of the targeted
+// instances apply the same objects, so pruning them here would delete the objects those controllers just applied.
+// They are pruned when the extension is deleted or spec.applyManifests is disabled.
+func (r *ArgoCDExtensionReconciler) removeUntargeted(ctx context.Context, ext *extensionv1.ArgoCDExtension) error {
+	extensionCtx := extension.NewExtensionContext(ext, r.ExtensionsPath, extension.Options{})
+	if !extensionCtx.Installed() {
+		return nil
+	}
+	k8slog.FromContext(ctx).Info("Removing extension targeting other Argo CD instances")
+	if err := r.deleteSettings(ctx, ext); err != nil {
+		return err
+	}
+	if err := extensionCtx.ProcessDeletion(ctx); err != nil {
+		return err
+	}
+	metrics.DeleteExtension(ext.Namespace, ext.Name)
+	return nil
+}
@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
 	"os"
+	"strings"
 
 	"sigs.k8s.io/controller-runtime/pkg/client/config"
 
@@ -10,16 +13,25 @@ import (
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/clientcmd"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
 
-	extensionv1 "github.com/argoproj/argocd-extensions/api/v1alpha1"
+	extensionv1alpha1 "github.com/argoproj/argocd-extensions/api/v1alpha1"
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
 	"github.com/argoproj/argocd-extensions/controllers"
+	extensionsconfig "github.com/argoproj/argocd-extensions/pkg/config"
+	"github.com/argoproj/argocd-extensions/pkg/generators"
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+	"github.com/argoproj/argocd-extensions/pkg/tracing"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -31,53 +43,184 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(extensionv1alpha1.AddToScheme(scheme))
 	utilruntime.Must(extensionv1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
-	opts := zap.Options{
-		Development: true,
-	}
-	opts.BindFlags(flag.CommandLine)
+	var configPath string
+	defaults := extensionsconfig.Default()
+	defaults.BindFlags(flag.CommandLine)
+	flag.StringVar(&configPath, "config", "",
+		"The configuration file, typically mounted from a ConfigMap, holding the settings that are not set with flags.")
 	flag.Parse()
 
-	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
-
-	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
-	if explicitPath := flag.Lookup("kubeconfig").Value.String(); explicitPath != "" {
-		loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: explicitPath}
-	}
-
-	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
-	namespace, _, err := clientConfig.Namespace()
+	settings, err := extensionsconfig.NewStore(configPath, flag.CommandLine)
 	if err != nil {
-		setupLog.Error(err, "unable to get namespace")
+		ctrl.SetLogger(zap.New())
+		setupLog.Error(err, "unable to load configuration")
 		os.Exit(1)
 	}
+	cfg := settings.Get()
+	ctrl.SetLogger(extensionsconfig.NewLogger(cfg, settings.Level()))
+	flag.Visit(func(f *flag.Flag) {
+		if strings.HasPrefix(f.Name, "zap-") {
+			setupLog.Info("Flag is deprecated, use --log-level and --log-format instead", "flag", f.Name)
+		}
+	})
+
+	// namespaces is empty if all namespaces are watched
+	namespaces := cfg.Namespaces
+	if len(namespaces) == 1 && namespaces[0] == extensionsconfig.AllNamespaces {
+		namespaces = nil
+	} else if len(namespaces) == 0 && cfg.NamespaceSelector == "" && len(cfg.ExtensionFiles) == 0 && os.Getenv(controllers.InstallerURLEnv) == "" {
+		namespace, err := kubeconfigNamespace()
+		if err != nil {
+			setupLog.Error(err, "unable to get namespace")
+			os.Exit(1)
+		}
+		namespaces = []string{namespace}
+	}
+	if cfg.Once {
+		if err := runOnce(ctrl.SetupSignalHandler(), settings, namespaces); err != nil {
+			setupLog.Error(err, "unable to install extensions")
+			os.Exit(1)
+		}
+		return
+	}
 
-	mgr, err := ctrl.NewManager(config.GetConfigOrDie(), ctrl.Options{
+	options := ctrl.Options{
 		Scheme:                 scheme,
 		Port:                   9443,
-		HealthProbeBindAddress: "0",
-		LeaderElection:         false,
-		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: cfg.HealthProbeBindAddress,
+		LeaderElection:         cfg.LeaderElection,
+		MetricsBindAddress:     cfg.MetricsBindAddress,
 		LeaderElectionID:       "632aad60.argoproj.io",
-		Namespace:              namespace,
-	})
+	}
+	switch {
+	case len(namespaces) == 1:
+		options.Namespace = namespaces[0]
+	case len(namespaces) > 1:
+		options.NewCache = cache.MultiNamespacedCacheBuilder(namespaces)
+	}
+	mgr, err := ctrl.NewManager(config.GetConfigOrDie(), options)
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
+	if err := mgr.Add(settings); err != nil {
+		setupLog.Error(err, "unable to set up configuration reload")
+		os.Exit(1)
+	}
+	// the Argo CD ConfigMaps are read from the API server if the Argo CD namespace is not watched, so the extensions of
+	// that namespace are only installed if it is listed
+	var settingsClient client.Client
+	if len(namespaces) > 0 && cfg.ArgoCDNamespace != "" && !contains(namespaces, cfg.ArgoCDNamespace) {
+		if cfg.ClusterExtensions && cfg.Mode != extensionsconfig.ModeAgent {
+			// cluster extensions are installed by the extensions they manage in the Argo CD namespace
+			setupLog.Error(errors.New("argocdNamespace must be listed in namespaces along with clusterExtensions"),
+				"invalid configuration")
+			os.Exit(1)
+		}
+		settingsClient, err = client.NewDelegatingClient(client.NewDelegatingClientInput{
+			CacheReader: mgr.GetAPIReader(),
+			Client:      mgr.GetClient(),
+		})
+		if err != nil {
+			setupLog.Error(err, "unable to create Argo CD settings client")
+			os.Exit(1)
+		}
+	}
+
+	// the selector is validated when the configuration is loaded
+	selector, _ := labels.Parse(cfg.NamespaceSelector)
+	namespaceFilter := &controllers.NamespaceFilter{Client: mgr.GetClient(), Selector: selector}
+
+	// every replica of the Argo CD server reports its state unless a single leader installs extensions. The
+	// coordinator aggregates the states reported by the agents.
+	var replica *controllers.ReplicaIdentity
+	podName := os.Getenv("POD_NAME")
+	if cfg.Mode == extensionsconfig.ModeCoordinator {
+		podName = ""
+	} else if cfg.Mode == extensionsconfig.ModeAgent && podName == "" {
+		setupLog.Error(errors.New("POD_NAME is not set"), "agents must report their state")
+		os.Exit(1)
+	}
+	if cfg.Mode == extensionsconfig.ModeCoordinator || podName != "" && !cfg.LeaderElection {
+		podNamespace := os.Getenv("POD_NAMESPACE")
+		if podNamespace == "" {
+			if podNamespace, err = kubeconfigNamespace(); err != nil {
+				setupLog.Error(err, "unable to get namespace")
+				os.Exit(1)
+			}
+		}
+		replica = &controllers.ReplicaIdentity{Pod: podName, Namespace: podNamespace, Reader: mgr.GetAPIReader()}
+	}
 
-	if err = (&controllers.ArgoCDExtensionReconciler{
-		Client:         mgr.GetClient(),
-		Scheme:         mgr.GetScheme(),
-		ExtensionsPath: "/tmp/extensions",
-	}).SetupWithManager(mgr); err != nil {
+	metrics.Register(cfg.ExtensionsPath)
+	extensionReconciler := &controllers.ArgoCDExtensionReconciler{
+		Client:          mgr.GetClient(),
+		Scheme:          mgr.GetScheme(),
+		ExtensionsPath:  cfg.ExtensionsPath,
+		ArgoCDVersion:   cfg.ArgoCDVersion,
+		VerifyLua:       cfg.VerifyLua,
+		Recorder:        mgr.GetEventRecorderFor("argocd-extensions"),
+		Settings:        settings,
+		ArgoCDNamespace: cfg.ArgoCDNamespace,
+		SettingsClient:  settingsClient,
+		Namespaces:      namespaceFilter,
+		InstanceLabels:  cfg.InstanceLabels,
+		Replica:         replica,
+		Mode:            cfg.Mode,
+	}
+	if err = extensionReconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "ArgoCDExtension")
 		os.Exit(1)
 	}
+	// agents only install the artifacts published by the coordinator, which manages the other resources
+	if cfg.Mode != extensionsconfig.ModeAgent {
+		if cfg.ClusterExtensions {
+			if err = (&controllers.ClusterArgoCDExtensionReconciler{
+				Client:          mgr.GetClient(),
+				Scheme:          mgr.GetScheme(),
+				ArgoCDNamespace: cfg.ArgoCDNamespace,
+				Namespaces:      namespaces,
+				NamespaceFilter: namespaceFilter,
+			}).SetupWithManager(mgr); err != nil {
+				setupLog.Error(err, "unable to create controller", "controller", "ClusterArgoCDExtension")
+				os.Exit(1)
+			}
+		}
+		if err = (&controllers.ArgoCDExtensionCatalogReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Settings:   settings,
+			Namespaces: namespaceFilter,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ArgoCDExtensionCatalog")
+			os.Exit(1)
+		}
+		if err = (&controllers.ArgoCDExtensionSetReconciler{
+			Client:     mgr.GetClient(),
+			Scheme:     mgr.GetScheme(),
+			Generator:  generators.NewGenerator(),
+			Settings:   settings,
+			Namespaces: namespaceFilter,
+		}).SetupWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create controller", "controller", "ArgoCDExtensionSet")
+			os.Exit(1)
+		}
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = ctrl.NewWebhookManagedBy(mgr).For(&extensionv1.ArgoCDExtension{}).Complete(); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "ArgoCDExtension")
+			os.Exit(1)
+		}
+		mgr.GetWebhookServer().Register("/warn-v1beta1-argocdextension-delete", &webhook.Admission{
+			Handler: &controllers.DeletionWarningWebhook{Client: mgr.GetClient()},
+		})
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -88,10 +231,51 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("extensions-path", controllers.WritableCheck(cfg.ExtensionsPath)); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+	if err := mgr.AddReadyzCheck("extensions-installed", extensionReconciler.InstalledCheck()); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	shutdownTracing, err := tracing.Init(ctx)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			setupLog.Error(err, "unable to flush traces")
+		}
+	}()
 
 	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
 		os.Exit(1)
 	}
 }
+
+// kubeconfigNamespace returns the namespace of the kubeconfig context, or the namespace of the pod in cluster
+func kubeconfigNamespace() (string, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if explicitPath := flag.Lookup("kubeconfig").Value.String(); explicitPath != "" {
+		loadingRules = &clientcmd.ClientConfigLoadingRules{ExplicitPath: explicitPath}
+	}
+
+	clientConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, &clientcmd.ConfigOverrides{})
+	namespace, _, err := clientConfig.Namespace()
+	return namespace, err
+}
+
+func contains(items []string, item string) bool {
+	for _, i := range items {
+		if i == item {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/controllers"
+	extensionsconfig "github.com/argoproj/argocd-extensions/pkg/config"
+)
+
+// runOnce installs the extensions configured by the argocd-extension-installer environment or read from the configured
+// files, or else the extensions listed from the watched namespaces, into the extensions directory
+func runOnce(ctx context.Context, settings *extensionsconfig.Store, namespaces []string) error {
+	cfg := settings.Get()
+	r := &controllers.ArgoCDExtensionReconciler{
+		ExtensionsPath: cfg.ExtensionsPath,
+		ArgoCDVersion:  cfg.ArgoCDVersion,
+		VerifyLua:      cfg.VerifyLua,
+		Settings:       settings,
+		InstanceLabels: cfg.InstanceLabels,
+	}
+	// extensions read from files or the installer environment might be installed without API server access
+	namespace := os.Getenv("POD_NAMESPACE")
+	if namespace == "" {
+		namespace = metav1.NamespaceDefault
+	}
+	extensions, fromEnv, err := controllers.InstallerExtensions(os.Getenv, namespace)
+	if err != nil {
+		return err
+	}
+	if len(cfg.ExtensionFiles) > 0 {
+		loaded, err := controllers.LoadExtensions(cfg.ExtensionFiles, namespace)
+		if err != nil {
+			return err
+		}
+		extensions = append(extensions, loaded...)
+	}
+	if fromEnv || len(cfg.ExtensionFiles) > 0 {
+		return r.InstallOnce(ctx, extensions)
+	}
+
+	restConfig, err := config.GetConfig()
+	if err != nil {
+		return err
+	}
+	c, err := client.New(restConfig, client.Options{Scheme: scheme})
+	if err != nil {
+		return err
+	}
+	r.Client = c
+	// the selector is validated when the configuration is loaded
+	selector, _ := labels.Parse(cfg.NamespaceSelector)
+	filter := &controllers.NamespaceFilter{Client: c, Selector: selector}
+	if len(namespaces) == 0 {
+		namespaces = []string{""}
+	}
+	for _, ns := range namespaces {
+		var list extensionv1.ArgoCDExtensionList
+		if err := c.List(ctx, &list, client.InNamespace(ns)); err != nil {
+			return err
+		}
+		for _, ext := range list.Items {
+			if matches, err := filter.Matches(ctx, ext.Namespace); err != nil {
+				return err
+			} else if matches && ext.DeletionTimestamp == nil {
+				extensions = append(extensions, ext)
+			}
+		}
+	}
+	return r.InstallOnce(ctx, extensions)
+}
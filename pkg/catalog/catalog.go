@@ -0,0 +1,251 @@
+package catalog
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/git"
+)
+
+const (
+	// DefaultIndexPath is the index file path used if the Git source does not specify one
+	DefaultIndexPath = "index.yaml"
+	// DefaultChannel is the channel used if the catalog reference does not specify one
+	DefaultChannel = "stable"
+)
+
+// index is the structure of the catalog index file
+type index struct {
+	Extensions []extensionv1.CatalogExtension `json:"extensions"`
+}
+
+// Options bounds the network operations of fetching the catalog index
+type Options struct {
+	// ResolveTimeout bounds resolving the revision of a Git source, zero disables the timeout
+	ResolveTimeout time.Duration
+	// DownloadTimeout bounds cloning the repository or downloading the index, zero disables the timeout
+	DownloadTimeout time.Duration
+}
+
+// Fetch downloads and parses the catalog index. Returns the resolved revision of the index along with the listed
+// extensions: the commit SHA for Git sources and the content SHA-256 for Web sources.
+func Fetch(ctx context.Context, source extensionv1.ExtensionSource, opts Options) (string, []extensionv1.CatalogExtension, error) {
+	tempDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return "", nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+
+	var indexPath, revision string
+	switch {
+	case source.Type == extensionv1.SourceTypeGit && source.Git != nil:
+		resolveCtx, cancel := withTimeout(ctx, opts.ResolveTimeout)
+		sha, err := git.LsRemoteContext(resolveCtx, source.Git.Url, source.Git.Revision)
+		cancel()
+		if err != nil {
+			return "", nil, err
+		}
+		repoPath := filepath.Join(tempDir, "repo")
+		downloadCtx, cancel := withTimeout(ctx, opts.DownloadTimeout)
+		defer cancel()
+		if err := git.CheckoutContext(downloadCtx, source.Git.Url, sha, repoPath); err != nil {
+			return "", nil, err
+		}
+		relPath := source.Git.Path
+		if relPath == "" {
+			relPath = DefaultIndexPath
+		}
+		clean := filepath.Clean(relPath)
+		if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+			return "", nil, fmt.Errorf("index path %s must be relative to the repository root", relPath)
+		}
+		indexPath = filepath.Join(repoPath, clean)
+		revision = sha
+	case source.Type == extensionv1.SourceTypeWeb && source.Web != nil:
+		webURL, err := extension.WebSourceURL(source.Web)
+		if err != nil {
+			return "", nil, err
+		}
+		indexPath = filepath.Join(tempDir, "index")
+		downloadCtx, cancel := withTimeout(ctx, opts.DownloadTimeout)
+		defer cancel()
+		if err := getter.GetFile(indexPath, "http::"+webURL, getter.WithContext(downloadCtx)); err != nil {
+			return "", nil, err
+		}
+	default:
+		return "", nil, errors.New("source must specify git or web")
+	}
+
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return "", nil, err
+	}
+	if revision == "" {
+		revision = fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+	}
+	extensions, err := Parse(data)
+	if err != nil {
+		return "", nil, err
+	}
+	return revision, extensions, nil
+}
+
+// withTimeout returns a context that is cancelled after the timeout, or a cancellable context if the timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// Parse parses and validates the catalog index
+func Parse(data []byte) ([]extensionv1.CatalogExtension, error) {
+	var idx index
+	if err := yaml.UnmarshalStrict(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %v", err)
+	}
+	names := map[string]bool{}
+	for _, ext := range idx.Extensions {
+		if errs := validation.IsDNS1123Subdomain(ext.Name); len(errs) > 0 {
+			return nil, fmt.Errorf("extension name %q is invalid: %v", ext.Name, errs)
+		}
+		if names[ext.Name] {
+			return nil, fmt.Errorf("extension %s is listed more than once", ext.Name)
+		}
+		names[ext.Name] = true
+		if err := validateVersions(ext.Versions); err != nil {
+			return nil, fmt.Errorf("extension %s: %v", ext.Name, err)
+		}
+	}
+	return idx.Extensions, nil
+}
+
+func validateVersions(versions []extensionv1.CatalogVersion) error {
+	seen := map[string]bool{}
+	for _, v := range versions {
+		parsed, err := version.NewVersion(v.Version)
+		if err != nil {
+			return fmt.Errorf("version %q is invalid: %v", v.Version, err)
+		}
+		if seen[parsed.String()] {
+			return fmt.Errorf("version %s is listed more than once", v.Version)
+		}
+		seen[parsed.String()] = true
+		if v.ArgoCDVersion != "" {
+			if _, err := version.NewConstraint(v.ArgoCDVersion); err != nil {
+				return fmt.Errorf("version %s argocdVersion %s is invalid: %v", v.Version, v.ArgoCDVersion, err)
+			}
+		}
+		if len(v.Sources) == 0 {
+			return fmt.Errorf("version %s has no sources", v.Version)
+		}
+		for i, s := range v.Sources {
+			if (s.Type == extensionv1.SourceTypeGit && s.Git == nil) || (s.Type == extensionv1.SourceTypeWeb && s.Web == nil) ||
+				(s.Type != extensionv1.SourceTypeGit && s.Type != extensionv1.SourceTypeWeb) {
+				return fmt.Errorf("version %s source #%d must specify git or web according to its type", v.Version, i)
+			}
+		}
+	}
+	return nil
+}
+
+// Resolve selects the catalog version referenced by the extension. If the reference does not pin a version, the
+// latest version of the channel that is compatible with the running Argo CD is selected. Otherwise the pinned
+// version is selected and the latest compatible version of the channel is returned if it is newer.
+func Resolve(extensions []extensionv1.CatalogExtension, ref extensionv1.CatalogReference, argocdVersion string) (*extensionv1.CatalogVersion, string, error) {
+	var ext *extensionv1.CatalogExtension
+	for i := range extensions {
+		if extensions[i].Name == ref.Extension {
+			ext = &extensions[i]
+		}
+	}
+	if ext == nil {
+		return nil, "", fmt.Errorf("extension %s is not listed in catalog %s", ref.Extension, ref.Name)
+	}
+	channel := ref.Channel
+	if channel == "" {
+		channel = DefaultChannel
+	}
+
+	// versions are validated when the index is fetched, so invalid versions are only skipped defensively
+	type candidate struct {
+		catalogVersion *extensionv1.CatalogVersion
+		version        *version.Version
+	}
+	var all, candidates []candidate
+	for i := range ext.Versions {
+		parsed, err := version.NewVersion(ext.Versions[i].Version)
+		if err != nil {
+			continue
+		}
+		c := candidate{catalogVersion: &ext.Versions[i], version: parsed}
+		all = append(all, c)
+		if hasChannel(c.catalogVersion, channel) && checkCompatibility(ext.Name, c.catalogVersion, argocdVersion) == nil {
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].version.GreaterThan(candidates[j].version)
+	})
+
+	if ref.Version == "" {
+		if len(candidates) == 0 {
+			return nil, "", fmt.Errorf("%w: catalog %s has no %s version of %s supporting Argo CD %s",
+				extension.ErrIncompatible, ref.Name, channel, ext.Name, argocdVersion)
+		}
+		return candidates[0].catalogVersion, "", nil
+	}
+
+	pinned, err := version.NewVersion(ref.Version)
+	if err != nil {
+		return nil, "", fmt.Errorf("version %s is invalid: %v", ref.Version, err)
+	}
+	var selected *extensionv1.CatalogVersion
+	for _, c := range all {
+		if c.version.Equal(pinned) {
+			selected = c.catalogVersion
+		}
+	}
+	if selected == nil {
+		return nil, "", fmt.Errorf("version %s of %s is not listed in catalog %s", ref.Version, ext.Name, ref.Name)
+	}
+	if err := checkCompatibility(ext.Name, selected, argocdVersion); err != nil {
+		return nil, "", err
+	}
+	available := ""
+	if len(candidates) > 0 && candidates[0].version.GreaterThan(pinned) {
+		available = candidates[0].catalogVersion.Version
+	}
+	return selected, available, nil
+}
+
+func hasChannel(v *extensionv1.CatalogVersion, channel string) bool {
+	for _, c := range v.Channels {
+		if c == channel {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCompatibility returns ErrIncompatible if the version does not support the running Argo CD
+func checkCompatibility(name string, v *extensionv1.CatalogVersion, argocdVersion string) error {
+	manifest := extension.Manifest{Name: name, ArgoCDVersion: v.ArgoCDVersion}
+	return manifest.CheckCompatibility(argocdVersion)
+}
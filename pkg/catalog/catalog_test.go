@@ -0,0 +1,170 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+)
+
+func TestResolve(t *testing.T) {
+	catalogVersion := func(v string, argocdVersion string, channels ...string) extensionv1.CatalogVersion {
+		return extensionv1.CatalogVersion{Version: v, Channels: channels, ArgoCDVersion: argocdVersion}
+	}
+	extensions := []extensionv1.CatalogExtension{{
+		Name: "metrics",
+		Versions: []extensionv1.CatalogVersion{
+			catalogVersion("1.0.0", "", "stable"),
+			catalogVersion("1.10.0", ">= 2.5", "stable"),
+			catalogVersion("1.2.0", "", "stable"),
+			catalogVersion("2.0.0", ">= 2.8", "stable"),
+			catalogVersion("2.1.0-rc.1", "", "beta"),
+		},
+	}}
+	for _, tc := range []struct {
+		name          string
+		ref           extensionv1.CatalogReference
+		argocdVersion string
+		want          string
+		wantAvailable string
+		wantErr       bool
+		wantErrIs     error
+	}{
+		{name: "latest without Argo CD version", ref: extensionv1.CatalogReference{Extension: "metrics"}, want: "2.0.0"},
+		{name: "latest compatible", ref: extensionv1.CatalogReference{Extension: "metrics"}, argocdVersion: "v2.6.0", want: "1.10.0"},
+		{name: "semantic ordering", ref: extensionv1.CatalogReference{Extension: "metrics"}, argocdVersion: "v2.4.0", want: "1.2.0"},
+		{name: "channel", ref: extensionv1.CatalogReference{Extension: "metrics", Channel: "beta"}, want: "2.1.0-rc.1"},
+		{name: "channel without constraint", ref: extensionv1.CatalogReference{Extension: "metrics", Channel: "beta"}, argocdVersion: "v2.6.0",
+			want: "2.1.0-rc.1"},
+		{name: "empty channel", ref: extensionv1.CatalogReference{Extension: "metrics", Channel: "nightly"}, wantErrIs: extension.ErrIncompatible},
+		{name: "pinned", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "1.2.0"}, argocdVersion: "v2.6.0",
+			want: "1.2.0", wantAvailable: "1.10.0"},
+		{name: "pinned latest", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "1.10.0"}, argocdVersion: "v2.6.0",
+			want: "1.10.0"},
+		{name: "pinned without leading zeros", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "1.2"}, want: "1.2.0",
+			wantAvailable: "2.0.0"},
+		{name: "pinned incompatible", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "2.0.0"}, argocdVersion: "v2.6.0",
+			wantErrIs: extension.ErrIncompatible},
+		{name: "pinned not listed", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "3.0.0"}, wantErr: true},
+		{name: "pinned invalid", ref: extensionv1.CatalogReference{Extension: "metrics", Version: "latest"}, wantErr: true},
+		{name: "unknown extension", ref: extensionv1.CatalogReference{Extension: "logs"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, available, err := Resolve(extensions, tc.ref, tc.argocdVersion)
+			if tc.wantErr || tc.wantErrIs != nil {
+				if err == nil || tc.wantErrIs != nil && !errors.Is(err, tc.wantErrIs) {
+					t.Fatalf("Resolve() error = %v, want %v", err, tc.wantErrIs)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Version != tc.want || available != tc.wantAvailable {
+				t.Errorf("Resolve() = %s, %q, want %s, %q", got.Version, available, tc.want, tc.wantAvailable)
+			}
+		})
+	}
+}
+
+func TestFetch(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/hung") {
+			<-hung
+			return
+		}
+		_, _ = w.Write([]byte("extensions:\n- name: metrics\n  versions: []\n"))
+	}))
+	defer server.Close()
+	defer close(hung)
+	webSource := func(path string) extensionv1.ExtensionSource {
+		return extensionv1.ExtensionSource{
+			Type: extensionv1.SourceTypeWeb, Web: &extensionv1.WebSource{Url: server.URL + path},
+		}
+	}
+	opts := Options{ResolveTimeout: 100 * time.Millisecond, DownloadTimeout: 100 * time.Millisecond}
+
+	revision, extensions, err := Fetch(context.Background(), webSource("/index.yaml"), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(revision, "sha256:") || len(extensions) != 1 || extensions[0].Name != "metrics" {
+		t.Errorf("Fetch() = %s, %v", revision, extensions)
+	}
+
+	// a stalled remote does not block the caller beyond the timeouts
+	start := time.Now()
+	if _, _, err := Fetch(context.Background(), webSource("/hung.yaml"), opts); err == nil {
+		t.Error("Fetch() of a stalled index succeeded")
+	}
+	gitSource := extensionv1.ExtensionSource{Type: extensionv1.SourceTypeGit, Git: &extensionv1.GitSource{
+		Url: server.URL + "/hung.git", Revision: "main",
+	}}
+	if _, _, err := Fetch(context.Background(), gitSource, opts); err == nil {
+		t.Error("Fetch() of a stalled Git repository succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Fetch() returned after %s, want about the timeouts", elapsed)
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		index   string
+		wantErr bool
+	}{
+		{name: "valid", index: `
+extensions:
+- name: metrics
+  versions:
+  - version: 1.0.0
+    channels: [stable]
+    argocdVersion: ">= 2.5"
+    sources:
+    - type: Web
+      web:
+        url: https://example.com/metrics-1.0.0.tar
+`},
+		{name: "invalid name", index: "extensions:\n- name: Metrics\n  versions: []\n", wantErr: true},
+		{name: "duplicate extension", index: "extensions:\n- name: metrics\n  versions: []\n- name: metrics\n  versions: []\n", wantErr: true},
+		{name: "invalid version", index: `
+extensions:
+- name: metrics
+  versions:
+  - version: latest
+    sources: [{type: Web, web: {url: https://example.com/metrics.tar}}]
+`, wantErr: true},
+		{name: "duplicate version", index: `
+extensions:
+- name: metrics
+  versions:
+  - version: 1.0.0
+    sources: [{type: Web, web: {url: https://example.com/metrics.tar}}]
+  - version: "1.0"
+    sources: [{type: Web, web: {url: https://example.com/metrics.tar}}]
+`, wantErr: true},
+		{name: "no sources", index: "extensions:\n- name: metrics\n  versions:\n  - version: 1.0.0\n", wantErr: true},
+		{name: "source type mismatch", index: `
+extensions:
+- name: metrics
+  versions:
+  - version: 1.0.0
+    sources: [{type: Git, web: {url: https://example.com/metrics.tar}}]
+`, wantErr: true},
+		{name: "unknown field", index: "extensions:\n- name: metrics\n  version: 1.0.0\n", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Parse([]byte(tc.index)); (err != nil) != tc.wantErr {
+				t.Errorf("Parse() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
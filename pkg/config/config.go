@@ -0,0 +1,341 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// AllNamespaces is the Namespaces item selecting all namespaces
+	AllNamespaces = "*"
+
+	// LogFormatText logs human-readable lines
+	LogFormatText = "text"
+	// LogFormatJSON logs JSON objects
+	LogFormatJSON = "json"
+
+	// ModeStandalone resolves, downloads and installs every extension in every replica
+	ModeStandalone = "standalone"
+	// ModeCoordinator resolves and downloads every extension once and publishes its files as an artifact
+	ModeCoordinator = "coordinator"
+	// ModeAgent installs the artifacts published by the coordinator
+	ModeAgent = "agent"
+)
+
+// Config holds the controller configuration. Every setting can be set in the configuration file, typically mounted
+// from a ConfigMap, and with the command line flag of the same name. Explicitly set flags take precedence.
+type Config struct {
+	// Mode is either standalone, coordinator or agent
+	Mode string `json:"mode,omitempty"`
+	// Once installs all extensions into ExtensionsPath and exits, so the controller runs as an init container
+	Once bool `json:"once,omitempty"`
+	// ExtensionFiles lists YAML files or directories holding the ArgoCDExtension objects installed by Once instead of
+	// the objects listed from the API server
+	ExtensionFiles []string `json:"extensionFiles,omitempty"`
+	// ExtensionsPath is the directory receiving the extension files
+	ExtensionsPath string `json:"extensionsPath,omitempty"`
+	// Namespaces lists the watched namespaces, or holds AllNamespaces. The namespace of the kubeconfig context is
+	// watched if empty.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector restricting the watched namespaces
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+	// ArgoCDNamespace is the namespace of the Argo CD ConfigMaps updated for all extensions. The settings of every
+	// extension are updated in the namespace of the extension if empty.
+	ArgoCDNamespace string `json:"argocdNamespace,omitempty"`
+	// InstanceLabels identifies the Argo CD instance served by the controller. Extensions targeting instances with
+	// a selector that does not match the labels are not installed.
+	InstanceLabels map[string]string `json:"instanceLabels,omitempty"`
+	// ClusterExtensions enables installing ClusterArgoCDExtensions, which requires cluster-wide permissions
+	ClusterExtensions bool `json:"clusterExtensions,omitempty"`
+	// ArgoCDVersion is the running Argo CD version used to check extensions compatibility
+	ArgoCDVersion string `json:"argocdVersion,omitempty"`
+	// VerifyLua enables refusing to install extensions whose Lua tests fail
+	VerifyLua bool `json:"verifyLua,omitempty"`
+	// LogFormat is either text or json
+	LogFormat string `json:"logFormat,omitempty"`
+	// LogLevel is either debug, info or error
+	LogLevel string `json:"logLevel,omitempty"`
+	// RefreshInterval is the delay before extension sources and extension set Git generators are resolved again.
+	// Periodic refresh is disabled if zero.
+	RefreshInterval metav1.Duration `json:"refreshInterval,omitempty"`
+	// MaxConcurrentReconciles is the number of extensions installed in parallel
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+	// Timeouts bounds the network operations performed for every extension source
+	Timeouts Timeouts `json:"timeouts,omitempty"`
+	// MetricsBindAddress is the address of the Prometheus metrics endpoint, 0 disables the endpoint
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+	// HealthProbeBindAddress is the address of the health probe endpoints, 0 disables the endpoints
+	HealthProbeBindAddress string `json:"healthProbeBindAddress,omitempty"`
+	// LeaderElection enables leader election, so a single replica installs extensions
+	LeaderElection bool `json:"leaderElection,omitempty"`
+}
+
+// Timeouts bounds the network operations performed for every extension source. Zero disables the timeout.
+type Timeouts struct {
+	// Resolve bounds resolving the revision of a source
+	Resolve metav1.Duration `json:"resolve,omitempty"`
+	// Download bounds downloading the files of a source
+	Download metav1.Duration `json:"download,omitempty"`
+}
+
+// Default returns the default configuration. The environment of the argocd-extension-installer init container enables
+// Once and sets the download timeout, so the same image runs with the same environment.
+func Default() Config {
+	cfg := Config{
+		Mode:                    ModeStandalone,
+		Once:                    os.Getenv("EXTENSION_URL") != "",
+		ExtensionsPath:          "/tmp/extensions",
+		ArgoCDVersion:           os.Getenv("ARGOCD_VERSION"),
+		LogFormat:               LogFormatText,
+		LogLevel:                "info",
+		MaxConcurrentReconciles: 1,
+		Timeouts: Timeouts{
+			Resolve:  metav1.Duration{Duration: time.Minute},
+			Download: metav1.Duration{Duration: 10 * time.Minute},
+		},
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: ":8081",
+	}
+	if seconds, err := strconv.Atoi(os.Getenv("MAX_DOWNLOAD_SEC")); err == nil && seconds > 0 {
+		cfg.Timeouts.Download.Duration = time.Duration(seconds) * time.Second
+	}
+	return cfg
+}
+
+// BindFlags registers a flag for every setting. The current values are used as the flags defaults.
+func (c *Config) BindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Mode, "mode", c.Mode,
+		"Either standalone, coordinator to publish extension artifacts, or agent to install the published artifacts.")
+	fs.BoolVar(&c.Once, "once", c.Once,
+		"Install all extensions into the extensions path and exit, e.g. in an init container. Exits non-zero on failure.")
+	fs.Var((*stringList)(&c.ExtensionFiles), "extension-files",
+		"Comma-separated list of YAML files or directories holding the extensions installed with --once instead of the extensions listed from the API server.")
+	fs.StringVar(&c.ExtensionsPath, "extensions-path", c.ExtensionsPath, "The directory receiving the extension files.")
+	fs.Var((*stringList)(&c.Namespaces), "namespaces",
+		"Comma-separated list of watched namespaces, or * for all namespaces. The namespace of the kubeconfig context is watched if empty.")
+	fs.StringVar(&c.NamespaceSelector, "namespace-selector", c.NamespaceSelector,
+		"Label selector restricting the watched namespaces, e.g. argocd-extensions=enabled.")
+	fs.StringVar(&c.ArgoCDNamespace, "argocd-namespace", c.ArgoCDNamespace,
+		"The namespace of the Argo CD ConfigMaps updated for all extensions. Defaults to the namespace of every extension.")
+	fs.Var((*labelsValue)(&c.InstanceLabels), "instance-labels",
+		"Comma-separated key=value labels identifying the served Argo CD instance, matched by the target selector of extensions.")
+	fs.BoolVar(&c.ClusterExtensions, "cluster-extensions", c.ClusterExtensions,
+		"Install ClusterArgoCDExtensions into every served Argo CD instance. Requires cluster-wide permissions.")
+	fs.StringVar(&c.ArgoCDVersion, "argocd-version", c.ArgoCDVersion,
+		"The running Argo CD version used to check extensions compatibility. Compatibility checks are skipped if empty.")
+	fs.BoolVar(&c.VerifyLua, "verify-lua", c.VerifyLua, "Refuse to install extensions whose Lua health and action tests fail.")
+	fs.StringVar(&c.LogFormat, "log-format", c.LogFormat, "The log format, either text or json.")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel, "The log level, either debug, info or error.")
+	fs.DurationVar(&c.RefreshInterval.Duration, "refresh-interval", c.RefreshInterval.Duration,
+		"The delay before extension sources and extension set Git generators are resolved again. 0 disables periodic refresh.")
+	fs.IntVar(&c.MaxConcurrentReconciles, "max-concurrent-reconciles", c.MaxConcurrentReconciles,
+		"The number of extensions installed in parallel.")
+	fs.DurationVar(&c.Timeouts.Resolve.Duration, "resolve-timeout", c.Timeouts.Resolve.Duration,
+		"The timeout of resolving the revision of an extension source. 0 disables the timeout.")
+	fs.DurationVar(&c.Timeouts.Download.Duration, "download-timeout", c.Timeouts.Download.Duration,
+		"The timeout of downloading the files of an extension source. 0 disables the timeout.")
+	fs.StringVar(&c.MetricsBindAddress, "metrics-bind-address", c.MetricsBindAddress,
+		"The address the Prometheus metrics endpoint binds to, e.g. :8080. The endpoint is disabled if set to 0.")
+	fs.StringVar(&c.HealthProbeBindAddress, "health-probe-bind-address", c.HealthProbeBindAddress,
+		"The address the /healthz and /readyz probe endpoints bind to. The endpoints are disabled if set to 0.")
+	fs.BoolVar(&c.LeaderElection, "leader-elect", c.LeaderElection,
+		"Enable leader election, so a single replica installs extensions.")
+
+	// the controller-runtime zap flags are kept as aliases of the log level and format
+	fs.Var(&deprecatedFlag{apply: (*Config).setZapLevel}, "zap-log-level", "Deprecated: use --log-level.")
+	fs.Var(&deprecatedFlag{apply: (*Config).setZapEncoder}, "zap-encoder", "Deprecated: use --log-format.")
+	fs.Var(&deprecatedFlag{apply: (*Config).setZapDevel, boolFlag: true}, "zap-devel",
+		"Deprecated: use --log-level=debug and --log-format=text.")
+	fs.Var(&deprecatedFlag{apply: func(*Config, string) error { return nil }}, "zap-stacktrace-level",
+		"Deprecated: ignored.")
+}
+
+// setZapLevel maps the levels of --zap-log-level onto LogLevel, verbosity levels above info are logged at debug level
+func (c *Config) setZapLevel(value string) error {
+	if _, err := ParseLevel(value); err == nil {
+		c.LogLevel = value
+		return nil
+	}
+	if v, err := strconv.Atoi(value); err == nil && v > 0 {
+		c.LogLevel = "debug"
+		return nil
+	}
+	return fmt.Errorf("unsupported log level %q", value)
+}
+
+// setZapEncoder maps the console and json encoders of --zap-encoder onto LogFormat
+func (c *Config) setZapEncoder(value string) error {
+	switch value {
+	case "console":
+		c.LogFormat = LogFormatText
+	case "json":
+		c.LogFormat = LogFormatJSON
+	default:
+		return fmt.Errorf("unsupported encoder %q", value)
+	}
+	return nil
+}
+
+// setZapDevel maps --zap-devel onto the debug level and text format, the production mode keeps the configured ones
+func (c *Config) setZapDevel(value string) error {
+	devel, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	if devel {
+		c.LogLevel, c.LogFormat = "debug", LogFormatText
+	}
+	return nil
+}
+
+// Load reads the configuration file on top of the defaults and applies the flags explicitly set in fs. A missing
+// file is treated as empty, so an optional ConfigMap can be created later.
+func Load(path string, fs *flag.FlagSet) (Config, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return Config{}, err
+		}
+		if err := yaml.UnmarshalStrict(data, &cfg); err != nil {
+			return Config{}, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+	}
+
+	// explicitly set flags take precedence over the file
+	overrides := flag.NewFlagSet("", flag.ContinueOnError)
+	cfg.BindFlags(overrides)
+	var set []*flag.Flag
+	fs.Visit(func(f *flag.Flag) {
+		if override := overrides.Lookup(f.Name); override != nil {
+			set = append(set, override)
+		}
+	})
+	sort.SliceStable(set, func(i, j int) bool {
+		return flagRank(set[i]) < flagRank(set[j])
+	})
+	for _, override := range set {
+		value := fs.Lookup(override.Name).Value.String()
+		if deprecated, ok := override.Value.(*deprecatedFlag); ok {
+			if err := deprecated.apply(&cfg, value); err != nil {
+				return Config{}, err
+			}
+		} else if err := override.Value.Set(value); err != nil {
+			return Config{}, err
+		}
+	}
+	return cfg, cfg.Validate()
+}
+
+// flagRank orders the flags applied by Load. --zap-devel only sets defaults and the deprecated flags are replaced by
+// the current ones, so the more specific flags are applied last.
+func flagRank(f *flag.Flag) int {
+	if f.Name == "zap-devel" {
+		return 0
+	}
+	if _, ok := f.Value.(*deprecatedFlag); ok {
+		return 1
+	}
+	return 2
+}
+
+// Validate returns an error if a setting has an invalid value
+func (c Config) Validate() error {
+	if c.Mode != ModeStandalone && c.Mode != ModeCoordinator && c.Mode != ModeAgent {
+		return fmt.Errorf("unsupported mode %q", c.Mode)
+	}
+	if c.Mode == ModeAgent && c.LeaderElection {
+		return errors.New("leader election must not be enabled in agent mode, every agent installs extensions")
+	}
+	if len(c.ExtensionFiles) > 0 && !c.Once {
+		return errors.New("extensionFiles must only be set along with once")
+	}
+	if c.ExtensionsPath == "" {
+		return errors.New("extensionsPath must not be empty")
+	}
+	if c.LogFormat != LogFormatText && c.LogFormat != LogFormatJSON {
+		return fmt.Errorf("unsupported log format %q", c.LogFormat)
+	}
+	if _, err := ParseLevel(c.LogLevel); err != nil {
+		return err
+	}
+	if _, err := labels.Parse(c.NamespaceSelector); err != nil {
+		return fmt.Errorf("invalid namespace selector: %v", err)
+	}
+	for _, ns := range c.Namespaces {
+		if ns == AllNamespaces && len(c.Namespaces) > 1 {
+			return fmt.Errorf("namespaces must not list other namespaces along with %s", AllNamespaces)
+		}
+	}
+	if c.MaxConcurrentReconciles < 1 {
+		return errors.New("maxConcurrentReconciles must be positive")
+	}
+	return nil
+}
+
+// stringList is a flag.Value holding a comma-separated list of strings
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// deprecatedFlag is a flag.Value holding the value of a deprecated flag, applied by Load to the settings replacing it.
+// Setting the flag does not change the bound settings, so the value of the replacing flag is kept.
+type deprecatedFlag struct {
+	value    string
+	apply    func(*Config, string) error
+	boolFlag bool
+}
+
+func (f *deprecatedFlag) String() string {
+	return f.value
+}
+
+func (f *deprecatedFlag) Set(value string) error {
+	if err := f.apply(&Config{}, value); err != nil {
+		return err
+	}
+	f.value = value
+	return nil
+}
+
+func (f *deprecatedFlag) IsBoolFlag() bool {
+	return f.boolFlag
+}
+
+// labelsValue is a flag.Value holding comma-separated key=value labels
+type labelsValue map[string]string
+
+func (l *labelsValue) String() string {
+	return labels.Set(*l).String()
+}
+
+func (l *labelsValue) Set(value string) error {
+	parsed, err := labels.ConvertSelectorToLabelsMap(value)
+	if err != nil {
+		return err
+	}
+	*l = labelsValue(parsed)
+	return nil
+}
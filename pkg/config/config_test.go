@@ -0,0 +1,172 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// parseFlags returns the flag set of the controller after parsing args
+func parseFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	cfg := Default()
+	cfg.BindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		t.Fatal(err)
+	}
+	return fs
+}
+
+// writeConfig writes the configuration file and returns its path
+func writeConfig(t *testing.T, dir string, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Setenv("EXTENSION_URL", "")
+	t.Setenv("ARGOCD_VERSION", "")
+	t.Setenv("MAX_DOWNLOAD_SEC", "")
+	dir := t.TempDir()
+	path := writeConfig(t, dir, `
+logLevel: debug
+logFormat: json
+namespaces: [argocd, tools]
+refreshInterval: 5m
+timeouts:
+  resolve: 30s
+`)
+
+	// the file overrides the defaults and the explicitly set flags override the file
+	cfg, err := Load(path, parseFlags(t, "--log-level=error", "--max-concurrent-reconciles=4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "error" || cfg.MaxConcurrentReconciles != 4 {
+		t.Errorf("flags: logLevel = %s, maxConcurrentReconciles = %d", cfg.LogLevel, cfg.MaxConcurrentReconciles)
+	}
+	if cfg.LogFormat != LogFormatJSON || strings.Join(cfg.Namespaces, ",") != "argocd,tools" ||
+		cfg.RefreshInterval.Duration != 5*time.Minute || cfg.Timeouts.Resolve.Duration != 30*time.Second {
+		t.Errorf("file: %+v", cfg)
+	}
+	if cfg.Mode != ModeStandalone || cfg.Timeouts.Download.Duration != 10*time.Minute ||
+		cfg.ExtensionsPath != "/tmp/extensions" {
+		t.Errorf("defaults: %+v", cfg)
+	}
+
+	// flags left at their default value do not override the file
+	cfg, err = Load(path, parseFlags(t, "--log-format=text"))
+	if err != nil || cfg.LogLevel != "debug" || cfg.LogFormat != LogFormatText {
+		t.Errorf("Load() = %s %s, %v, want the debug level of the file and the text format of the flag",
+			cfg.LogLevel, cfg.LogFormat, err)
+	}
+
+	// a missing file is treated as empty
+	cfg, err = Load(filepath.Join(dir, "missing.yaml"), parseFlags(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "info" || cfg.LogFormat != LogFormatText {
+		t.Errorf("Load() of a missing file = %+v, want the defaults", cfg)
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		file    string
+		args    []string
+		wantErr string
+	}{
+		{name: "unknown field", file: "logLevl: debug", wantErr: "unknown field"},
+		{name: "invalid YAML", file: "namespaces: {", wantErr: "failed to parse"},
+		{name: "invalid mode", file: "mode: cluster", wantErr: `unsupported mode "cluster"`},
+		{name: "invalid mode flag", args: []string{"--mode=cluster"}, wantErr: `unsupported mode "cluster"`},
+		{name: "agent with leader election", file: "mode: agent\nleaderElection: true", wantErr: "agent mode"},
+		{name: "extension files without once", file: "extensionFiles: [extensions.yaml]", wantErr: "along with once"},
+		{name: "all namespaces and others", args: []string{"--namespaces=*,argocd"}, wantErr: "along with *"},
+		{name: "invalid selector", file: "namespaceSelector: '!!'", wantErr: "invalid namespace selector"},
+		{name: "no concurrent reconciles", file: "maxConcurrentReconciles: 0", wantErr: "must be positive"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := writeConfig(t, t.TempDir(), tc.file)
+			_, err := Load(path, parseFlags(t, tc.args...))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("Load() error = %v, want error containing %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadDeprecatedFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args       []string
+		wantLevel  string
+		wantFormat string
+	}{
+		{args: []string{"--zap-log-level=error"}, wantLevel: "error", wantFormat: LogFormatText},
+		{args: []string{"--zap-log-level=2"}, wantLevel: "debug", wantFormat: LogFormatText},
+		{args: []string{"--zap-encoder=json"}, wantLevel: "info", wantFormat: LogFormatJSON},
+		{args: []string{"--zap-devel"}, wantLevel: "debug", wantFormat: LogFormatText},
+		// the development mode only sets defaults, like in controller-runtime
+		{args: []string{"--zap-encoder=json", "--zap-devel"}, wantLevel: "debug", wantFormat: LogFormatJSON},
+		{args: []string{"--zap-devel=false", "--zap-stacktrace-level=panic"}, wantLevel: "info", wantFormat: LogFormatText},
+		// the flags replacing the deprecated flags are applied after them
+		{args: []string{"--log-level=error", "--zap-log-level=debug"}, wantLevel: "error", wantFormat: LogFormatText},
+	} {
+		t.Run(strings.Join(tc.args, " "), func(t *testing.T) {
+			cfg, err := Load("", parseFlags(t, tc.args...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.LogLevel != tc.wantLevel || cfg.LogFormat != tc.wantFormat {
+				t.Errorf("Load() = %s %s, want %s %s", cfg.LogLevel, cfg.LogFormat, tc.wantLevel, tc.wantFormat)
+			}
+		})
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(new(strings.Builder))
+	cfg := Default()
+	cfg.BindFlags(fs)
+	for _, arg := range []string{"--zap-log-level=trace", "--zap-encoder=logfmt"} {
+		if err := fs.Parse([]string{arg}); err == nil {
+			t.Errorf("parsing %s succeeded", arg)
+		}
+	}
+}
+
+func TestDefaultEnvironment(t *testing.T) {
+	t.Setenv("EXTENSION_URL", "")
+	t.Setenv("ARGOCD_VERSION", "")
+	t.Setenv("MAX_DOWNLOAD_SEC", "invalid")
+	if cfg := Default(); cfg.Once || cfg.ArgoCDVersion != "" || cfg.Timeouts.Download.Duration != 10*time.Minute {
+		t.Errorf("Default() = %+v", cfg)
+	}
+
+	// the environment of the argocd-extension-installer init container runs the controller once
+	t.Setenv("EXTENSION_URL", "https://example.com/extension.tar")
+	t.Setenv("ARGOCD_VERSION", "v2.4.0")
+	t.Setenv("MAX_DOWNLOAD_SEC", "30")
+	if cfg := Default(); !cfg.Once || cfg.ArgoCDVersion != "v2.4.0" || cfg.Timeouts.Download.Duration != 30*time.Second {
+		t.Errorf("Default() = %+v, want once with the environment version and download timeout", cfg)
+	}
+
+	// the file and flags still take precedence over the environment
+	path := writeConfig(t, t.TempDir(), "once: false\ntimeouts:\n  download: 1m")
+	cfg, err := Load(path, parseFlags(t, "--argocd-version=v2.5.0"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Once || cfg.ArgoCDVersion != "v2.5.0" || cfg.Timeouts.Download.Duration != time.Minute {
+		t.Errorf("Load() = %+v, want the file and flag settings", cfg)
+	}
+}
@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+	uberzap "go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+)
+
+// ParseLevel converts the debug, info and error log levels to zap levels
+func ParseLevel(level string) (zapcore.Level, error) {
+	switch level {
+	case "debug":
+		return zapcore.DebugLevel, nil
+	case "info":
+		return zapcore.InfoLevel, nil
+	case "error":
+		return zapcore.ErrorLevel, nil
+	}
+	return 0, fmt.Errorf("unsupported log level %q", level)
+}
+
+// NewLogger returns a logger using the configured format. The level is changed at runtime using the given level.
+func NewLogger(c Config, level uberzap.AtomicLevel) logr.Logger {
+	opts := []zap.Opts{zap.Level(level)}
+	if c.LogFormat == LogFormatJSON {
+		opts = append(opts, zap.JSONEncoder())
+	} else {
+		opts = append(opts, zap.ConsoleEncoder())
+	}
+	return zap.New(opts...)
+}
@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	uberzap "go.uber.org/zap"
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// reloadInterval is the delay between checks of the configuration file for changes
+const reloadInterval = 10 * time.Second
+
+// Store holds the current configuration and reloads the settings that can be safely changed at runtime when the
+// configuration file changes
+type Store struct {
+	path  string
+	flags *flag.FlagSet
+	level uberzap.AtomicLevel
+
+	mu      sync.RWMutex
+	current Config
+	data    []byte
+}
+
+// NewStore returns a store holding the configuration loaded from the file at path and the flags explicitly set in fs
+func NewStore(path string, fs *flag.FlagSet) (*Store, error) {
+	cfg, err := Load(path, fs)
+	if err != nil {
+		return nil, err
+	}
+	level, _ := ParseLevel(cfg.LogLevel)
+	s := &Store{path: path, flags: fs, level: uberzap.NewAtomicLevelAt(level), current: cfg}
+	s.data, _ = os.ReadFile(path)
+	return s, nil
+}
+
+// Get returns the current configuration, or the default configuration if the store is nil
+func (s *Store) Get() Config {
+	if s == nil {
+		return Default()
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// Level returns the log level updated when the configuration is reloaded
+func (s *Store) Level() uberzap.AtomicLevel {
+	return s.level
+}
+
+// Start checks the configuration file for changes until the context is done. The log level, refresh interval and
+// timeouts are applied immediately, changes of other settings are logged and take effect after a restart.
+func (s *Store) Start(ctx context.Context) error {
+	if s.path == "" {
+		return nil
+	}
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			s.reload(ctx)
+		}
+	}
+}
+
+// NeedLeaderElection returns false so the configuration is reloaded by every replica
+func (s *Store) NeedLeaderElection() bool {
+	return false
+}
+
+func (s *Store) reload(ctx context.Context) {
+	log := k8slog.FromContext(ctx).WithName("config")
+	data, err := os.ReadFile(s.path)
+	if err != nil && !os.IsNotExist(err) {
+		log.Error(err, "Failed to read configuration file")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if bytes.Equal(data, s.data) {
+		return
+	}
+	s.data = data
+	loaded, err := Load(s.path, s.flags)
+	if err != nil {
+		log.Error(err, "Ignoring invalid configuration")
+		return
+	}
+
+	next := s.current
+	next.LogLevel = loaded.LogLevel
+	next.RefreshInterval = loaded.RefreshInterval
+	next.Timeouts = loaded.Timeouts
+	if level, err := ParseLevel(next.LogLevel); err == nil {
+		s.level.SetLevel(level)
+	}
+	// the remaining settings are used to set up the manager and controllers
+	restart := loaded
+	restart.LogLevel, restart.RefreshInterval, restart.Timeouts = next.LogLevel, next.RefreshInterval, next.Timeouts
+	if !reflect.DeepEqual(restart, next) {
+		log.Info("Configuration changed, restart to apply settings other than the log level, refresh interval and timeouts")
+	}
+	s.current = next
+	log.Info("Reloaded configuration", "logLevel", next.LogLevel, "refreshInterval", next.RefreshInterval.Duration,
+		"resolveTimeout", next.Timeouts.Resolve.Duration, "downloadTimeout", next.Timeouts.Download.Duration)
+}
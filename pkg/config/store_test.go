@@ -0,0 +1,82 @@
+package config
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestStoreReload(t *testing.T) {
+	t.Setenv("EXTENSION_URL", "")
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "logLevel: info\nnamespaces: [argocd]\n")
+	s, err := NewStore(path, parseFlags(t, "--max-concurrent-reconciles=2"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the log level, refresh interval and timeouts are reloaded, the other settings need a restart
+	writeConfig(t, dir, `
+logLevel: debug
+logFormat: json
+namespaces: [argocd, tools]
+refreshInterval: 5m
+maxConcurrentReconciles: 8
+timeouts:
+  resolve: 10s
+  download: 1m
+`)
+	s.reload(ctx)
+	cfg := s.Get()
+	if cfg.LogLevel != "debug" || s.Level().Level() != zapcore.DebugLevel ||
+		cfg.RefreshInterval.Duration != 5*time.Minute || cfg.Timeouts.Resolve.Duration != 10*time.Second ||
+		cfg.Timeouts.Download.Duration != time.Minute {
+		t.Errorf("reloaded settings: %+v, level %s", cfg, s.Level().Level())
+	}
+	if cfg.LogFormat != LogFormatText || len(cfg.Namespaces) != 1 || cfg.MaxConcurrentReconciles != 2 {
+		t.Errorf("settings applied at startup changed: %+v", cfg)
+	}
+
+	// invalid configurations are ignored
+	writeConfig(t, dir, "logLevel: trace\nrefreshInterval: 1m\n")
+	s.reload(ctx)
+	if cfg := s.Get(); cfg.LogLevel != "debug" || cfg.RefreshInterval.Duration != 5*time.Minute {
+		t.Errorf("invalid configuration applied: %+v", cfg)
+	}
+
+	// a removed file restores the defaults of the reloaded settings
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	s.reload(ctx)
+	cfg = s.Get()
+	if cfg.LogLevel != "info" || s.Level().Level() != zapcore.InfoLevel || cfg.RefreshInterval.Duration != 0 {
+		t.Errorf("settings of the removed file kept: %+v", cfg)
+	}
+}
+
+func TestStoreReloadFlags(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	path := writeConfig(t, dir, "")
+	s, err := NewStore(path, parseFlags(t, "--log-level=error"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// explicitly set flags still take precedence over the reloaded file
+	writeConfig(t, dir, "logLevel: debug\nrefreshInterval: 1m\n")
+	s.reload(ctx)
+	if cfg := s.Get(); cfg.LogLevel != "error" || cfg.RefreshInterval.Duration != time.Minute {
+		t.Errorf("Get() = %+v, want the error level of the flag", cfg)
+	}
+
+	var nilStore *Store
+	if cfg := nilStore.Get(); cfg.LogLevel != "info" {
+		t.Errorf("Get() of a nil store = %+v, want the defaults", cfg)
+	}
+}
@@ -0,0 +1,176 @@
+package extension
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+	"github.com/argoproj/argocd-extensions/pkg/tracing"
+)
+
+// ErrInvalidArtifact is returned when the published artifact does not match its digest or can't be extracted
+var ErrInvalidArtifact = errors.New("invalid extension artifact")
+
+// Digest returns the sha256 digest of the artifact data
+func Digest(data []byte) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(data))
+}
+
+// Package archives the installed extension files into a gzipped tarball with paths relative to the extensions
+// directory. The archive only depends on the files content, so the same files always produce the same digest.
+func (c *extensionContext) Package(ctx context.Context) (_ []byte, err error) {
+	_, span := tracing.Start(ctx, "package", tracing.ExtensionName.String(c.name))
+	defer func() { tracing.End(span, err) }()
+
+	files := append([]string(nil), c.files...)
+	sort.Strings(files)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		relPath, err := filepath.Rel(c.outputPath, file)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := tw.WriteHeader(&tar.Header{
+			Name:     filepath.ToSlash(relPath),
+			Mode:     0644,
+			Size:     int64(len(data)),
+			Typeflag: tar.TypeReg,
+		}); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// InstallArtifact replaces the installed extension files with the files of the artifact published by the coordinator.
+// The artifact is only fetched if the artifact with the same digest is not installed yet.
+func (c *extensionContext) InstallArtifact(ctx context.Context, digest string, revisions []string, fetch func(context.Context) ([]byte, error)) (err error) {
+	ctx, span := tracing.Start(ctx, "installArtifact", tracing.ExtensionName.String(c.name))
+	defer func() { tracing.End(span, err) }()
+	log := k8slog.FromContext(ctx)
+
+	prev := c.loadSnapshot(ctx)
+	if prev.Digest == digest {
+		c.files = prev.Files
+		c.revisions = prev.Revisions
+		metrics.SetInstalledFiles(c.namespace, c.name, len(c.files))
+		return nil
+	}
+	data, err := fetch(ctx)
+	if err != nil {
+		return err
+	}
+	if actual := Digest(data); actual != digest {
+		return fmt.Errorf("%w: digest %s does not match the published digest %s", ErrInvalidArtifact, actual, digest)
+	}
+
+	tempDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return fmt.Errorf("failed to create temp dir %w", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tempDir); err != nil {
+			log.Error(err, "Failed to delete temp directory")
+		}
+	}()
+	paths, err := extractArtifact(data, tempDir)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidArtifact, err)
+	}
+
+	if err := prev.deleteFiles(); err != nil {
+		return fmt.Errorf("failed to clean %s: %v", c.outputPath, err)
+	}
+	snapshot := sourcesSnapshot{Revisions: revisions, Digest: digest}
+	for _, relPath := range paths {
+		targetPath := filepath.Join(c.outputPath, relPath)
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
+		}
+		if err := moveFile(filepath.Join(tempDir, relPath), targetPath); err != nil {
+			return fmt.Errorf("failed to move artifact files: %v", err)
+		}
+		snapshot.Files = append(snapshot.Files, targetPath)
+	}
+	if err := c.saveSnapshot(ctx, snapshot); err != nil {
+		return fmt.Errorf("failed to persist snapshot: %v", err)
+	}
+	if prev.Digest == "" {
+		c.changes = fmt.Sprintf("Artifact %s has not been installed yet", digest)
+	} else {
+		c.changes = fmt.Sprintf("Artifact has changed from %s to %s", prev.Digest, digest)
+	}
+	c.files = snapshot.Files
+	c.revisions = revisions
+	metrics.SetInstalledFiles(c.namespace, c.name, len(c.files))
+
+	log.Info("Successfully installed artifact.", "digest", digest)
+	return nil
+}
+
+// extractArtifact extracts the regular files of the artifact into the out directory and returns their relative paths
+func extractArtifact(data []byte, out string) ([]string, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	var res []string
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		targetPath, err := joinRelative(out, filepath.FromSlash(header.Name))
+		if err != nil || targetPath == out {
+			return nil, fmt.Errorf("artifact file %s is outside of the extensions directory", header.Name)
+		}
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return nil, err
+		}
+		file, err := os.Create(targetPath)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(file, tr)
+		_ = file.Close()
+		if err != nil {
+			return nil, err
+		}
+		relPath, _ := filepath.Rel(out, targetPath)
+		res = append(res, relPath)
+	}
+}
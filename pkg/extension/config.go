@@ -0,0 +1,112 @@
+package extension
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+const (
+	// configDir is the bundle directory that receives the configuration values of extensions
+	configDir = "config"
+	// templateSuffix marks bundle files that are rendered with the configuration values
+	templateSuffix = ".tmpl"
+)
+
+var templateFuncs = template.FuncMap{
+	"toJson": func(value interface{}) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+}
+
+// contentRevision returns a revision that changes whenever the configuration values or added files change
+func contentRevision(kind string, content interface{}) (string, error) {
+	data, err := json.Marshal(content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s#sha256:%x", kind, sha256.Sum256(data)), nil
+}
+
+// writeConfig stores the configuration values of the named extension in config/<name>.json
+func writeConfig(dir string, name string, values map[string]interface{}) error {
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return err
+	}
+	configPath := filepath.Join(dir, configDir, name+".json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, data, 0644)
+}
+
+// renderTemplates renders every file with the .tmpl suffix using Go templates and the configuration values, and
+// replaces it with the rendered file without the suffix. Referencing a missing value is an error.
+func renderTemplates(dir string, values map[string]interface{}) error {
+	var templates []string
+	if err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		if strings.HasSuffix(info.Name(), templateSuffix) {
+			templates = append(templates, path)
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	for _, path := range templates {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		target := strings.TrimSuffix(path, templateSuffix)
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s conflicts with the rendered file of %s", strings.TrimSuffix(relPath, templateSuffix), relPath)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		tmpl, err := template.New(relPath).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+		if err != nil {
+			return fmt.Errorf("failed to parse %s: %v", relPath, err)
+		}
+		var out strings.Builder
+		if err := tmpl.Execute(&out, values); err != nil {
+			return fmt.Errorf("failed to render %s: %v", relPath, err)
+		}
+		if err := os.WriteFile(target, []byte(out.String()), 0644); err != nil {
+			return err
+		}
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFiles adds the files to the bundle, paths must be relative to the bundle
+func writeFiles(dir string, files map[string][]byte) error {
+	for name, data := range files {
+		path, err := joinRelative(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
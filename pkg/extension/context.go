@@ -3,6 +3,7 @@ package extension
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -11,24 +12,74 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	k8slog "sigs.k8s.io/controller-runtime/pkg/log"
 
-	extensionv1 "github.com/argoproj/argocd-extensions/api/v1alpha1"
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
 	"github.com/argoproj/argocd-extensions/pkg/git"
+	"github.com/argoproj/argocd-extensions/pkg/metrics"
+	"github.com/argoproj/argocd-extensions/pkg/tracing"
 	"github.com/hashicorp/go-getter"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// gitBundleEntries lists the top-level entries of a Git repository that make up the extension
+var gitBundleEntries = []string{"resources", manifestsDir, manifestJSON, manifestYAML}
+
+var (
+	// ErrDownloadFailed is returned when the extension sources could not be resolved or downloaded
+	ErrDownloadFailed = errors.New("failed to download sources")
+	// ErrInvalidBundle is returned when the downloaded extension files can't be installed
+	ErrInvalidBundle = errors.New("invalid extension bundle")
+)
+
+// Options configures how extensions are installed
+type Options struct {
+	// ArgoCDVersion is the running Argo CD version used to check extensions compatibility
+	ArgoCDVersion string
+	// VerifyLua enables running the Lua health and action tests shipped in the extension before installing it
+	VerifyLua bool
+	// Config holds the configuration values of the extension. Values are written to config/<name>.json when set.
+	Config map[string]interface{}
+	// ResolveTimeout bounds resolving the revision of every source, zero disables the timeout
+	ResolveTimeout time.Duration
+	// DownloadTimeout bounds downloading every source, zero disables the timeout
+	DownloadTimeout time.Duration
+	// Version is included in the resolved revisions, so changing it installs the extension again
+	Version string
+	// Files are added to the downloaded bundle, keyed by their path relative to the bundle
+	Files map[string][]byte
+}
+
 type extensionContext struct {
+	namespace    string
 	name         string
 	outputPath   string
 	snapshotPath string
+	destination  string
+	options      Options
 	sources      []extensionv1.ExtensionSource
+	manifest     *Manifest
+	changes      string
+	revisions    []string
+	warnings     []string
+	files        []string
+	objects      []unstructured.Unstructured
 }
 
 type sourcesSnapshot struct {
-	Revisions []string `json:"revisions"`
-	Files     []string `json:"files"`
+	Revisions []string  `json:"revisions"`
+	Files     []string  `json:"files"`
+	Manifest  *Manifest `json:"manifest,omitempty"`
+	Warnings  []string  `json:"warnings,omitempty"`
+	// Objects holds the Kubernetes objects shipped in the manifests directory of the extension
+	Objects []unstructured.Unstructured `json:"objects,omitempty"`
+	// Digest is the digest of the installed artifact if the files were published by the coordinator
+	Digest string `json:"digest,omitempty"`
 }
 
 func (s *sourcesSnapshot) shouldDownload(revisions []string) string {
@@ -59,37 +110,81 @@ func (s sourcesSnapshot) deleteFiles() error {
 	return nil
 }
 
-func NewExtensionContext(extension *extensionv1.ArgoCDExtension, outputPath string) *extensionContext {
+func NewExtensionContext(extension *extensionv1.ArgoCDExtension, outputPath string, options Options) *extensionContext {
 	return &extensionContext{
+		namespace:    extension.Namespace,
 		name:         extension.Name,
 		sources:      extension.Spec.Sources,
+		destination:  extension.Spec.Destination.Path,
+		options:      options,
 		outputPath:   outputPath,
-		snapshotPath: path.Join(outputPath, fmt.Sprintf(".%s.snapshot", extension.Name)),
+		snapshotPath: path.Join(outputPath, fmt.Sprintf(".%s.%s.snapshot", extension.Namespace, extension.Name)),
 	}
 }
 
+// Manifest returns the manifest of the installed extension or nil if the extension has no manifest
+func (c *extensionContext) Manifest() *Manifest {
+	return c.manifest
+}
+
+// Files returns the paths of the installed extension files
+func (c *extensionContext) Files() []string {
+	return c.files
+}
+
+// Warnings returns the issues found in the installed extension files that did not prevent the installation
+func (c *extensionContext) Warnings() []string {
+	return c.warnings
+}
+
+// Objects returns the Kubernetes objects shipped in the manifests directory of the installed extension
+func (c *extensionContext) Objects() []unstructured.Unstructured {
+	return c.objects
+}
+
+// Revisions returns the revisions of the sources installed by the last successful Process call
+func (c *extensionContext) Revisions() []string {
+	return c.revisions
+}
+
+// Changes describes why the sources have been downloaded by the last Process call, e.g. the previous and new
+// revisions. Returns an empty string if the installed sources were up to date.
+func (c *extensionContext) Changes() string {
+	return c.changes
+}
+
 // Process downloads extension files
 func (c *extensionContext) Process(ctx context.Context) error {
 	log := k8slog.FromContext(ctx)
 
-	revisions, err := c.resolveRevisions()
+	resolveStart := time.Now()
+	revisions, commits, err := c.resolveRevisions(ctx)
+	metrics.ObserveResolve(c.namespace, c.name, time.Since(resolveStart))
 	if err != nil {
-		return fmt.Errorf("failed to resolve sources revisions: %v", err)
+		return fmt.Errorf("%w: failed to resolve sources revisions: %v", ErrDownloadFailed, err)
 	}
 
 	// try to load previous snapshot and check most recent revisions of all sources
-	prev := c.loadSnapshot()
+	prev := c.loadSnapshot(ctx)
 	reason := prev.shouldDownload(revisions)
 	if reason == "" {
+		// the running Argo CD might have been upgraded since the extension was installed
+		if prev.Manifest != nil {
+			if err := prev.Manifest.CheckCompatibility(c.options.ArgoCDVersion); err != nil {
+				return err
+			}
+		}
+		c.manifest = prev.Manifest
+		c.warnings = compatibilityWarnings(prev.Manifest, c.options.ArgoCDVersion, prev.Warnings)
+		c.files = prev.Files
+		c.objects = prev.Objects
+		c.revisions = prev.Revisions
+		metrics.SetInstalledFiles(c.namespace, c.name, len(c.files))
 		log.Info("Sources already downloaded.")
 		return nil
 	} else {
 		log.Info(fmt.Sprintf("%s, redownloading...", reason))
-	}
-
-	// delete all previously downloaded extension files
-	if err := prev.deleteFiles(); err != nil {
-		return fmt.Errorf("failed to clean %s: %v", c.outputPath, err)
+		c.changes = reason
 	}
 
 	// download all extension files into temp directory
@@ -103,37 +198,144 @@ func (c *extensionContext) Process(ctx context.Context) error {
 		}
 	}()
 
-	if err := c.downloadTo(tempDir); err != nil {
-		return fmt.Errorf("failed to download sources: %v", err)
+	downloadStart := time.Now()
+	if err := c.downloadTo(ctx, tempDir, commits); errors.Is(err, ErrInvalidBundle) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrDownloadFailed, err)
+	}
+	downloaded, err := metrics.DirSize(tempDir)
+	if err != nil {
+		return err
+	}
+	metrics.ObserveDownload(c.namespace, c.name, time.Since(downloadStart), downloaded)
+
+	// render environment-specific configuration into the bundle, bundles of extensions without configuration are
+	// installed as is since their .tmpl files might not be meant for the controller
+	if c.options.Config != nil {
+		if err := renderTemplates(tempDir, c.options.Config); err != nil {
+			return fmt.Errorf("%w: failed to render templates: %v", ErrInvalidBundle, err)
+		}
+		if err := writeConfig(tempDir, c.name, c.options.Config); err != nil {
+			return fmt.Errorf("failed to write configuration: %v", err)
+		}
+	}
+	if err := writeFiles(tempDir, c.options.Files); err != nil {
+		return fmt.Errorf("failed to write generated files: %v", err)
+	}
+
+	// parse extension manifest and refuse to install incompatible extension
+	manifest, err := loadManifest(tempDir)
+	if err != nil {
+		return fmt.Errorf("%w: failed to load extension manifest: %v", ErrInvalidBundle, err)
+	}
+	if manifest != nil {
+		if err := manifest.CheckCompatibility(c.options.ArgoCDVersion); err != nil {
+			return err
+		}
+	}
+
+	// Kubernetes objects are applied by the controller and must not be installed as extension files
+	objects, err := loadObjects(tempDir)
+	if err != nil {
+		return fmt.Errorf("%w: failed to load extension manifests: %v", ErrInvalidBundle, err)
+	}
+
+	// refuse to install extension with failing Lua fixtures
+	if c.options.VerifyLua {
+		if err := verifyLua(tempDir); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+	}
+
+	// delete all previously downloaded extension files
+	if err := prev.deleteFiles(); err != nil {
+		return fmt.Errorf("failed to clean %s: %v", c.outputPath, err)
 	}
 
 	// move downloaded files to the persistent extensions files location
 	// and store list of files in the snapshot
-	snapshot, err := c.moveSourceFiles(revisions, tempDir)
+	snapshot, err := c.moveSourceFiles(ctx, revisions, tempDir)
 	if err != nil {
 		return fmt.Errorf("failed to move source files: %v", err)
 	}
+	snapshot.Manifest = manifest
+	snapshot.Objects = objects
+
+	// make sure Argo CD is able to load installed files
+	warnings, err := validateBundle(manifest, snapshot.Files)
+	if err != nil {
+		if err := snapshot.deleteFiles(); err != nil {
+			log.Error(err, "Failed to delete invalid extension files")
+		}
+		return fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	snapshot.Warnings = warnings
 
 	// store snapshot in extensions directory
-	if err := c.saveSnapshot(snapshot); err != nil {
+	if err := c.saveSnapshot(ctx, snapshot); err != nil {
 		return fmt.Errorf("failed to persist snapshot: %v", err)
 	}
+	c.manifest = manifest
+	c.warnings = compatibilityWarnings(manifest, c.options.ArgoCDVersion, warnings)
+	c.files = snapshot.Files
+	c.objects = objects
+	c.revisions = revisions
+	metrics.SetInstalledFiles(c.namespace, c.name, len(c.files))
 
 	log.Info("Successfully downloaded all sources.")
 	return nil
 }
 
+// Installed returns true if the extension files have been installed, i.e. the snapshot of the installed sources exists
+func (c *extensionContext) Installed() bool {
+	_, err := os.Stat(c.snapshotPath)
+	return err == nil
+}
+
 // ProcessDeletion deletes all previously downloaded files for the extension
-func (c *extensionContext) ProcessDeletion() error {
-	err := c.loadSnapshot().deleteFiles()
+func (c *extensionContext) ProcessDeletion(ctx context.Context) error {
+	err := c.loadSnapshot(ctx).deleteFiles()
 	if err != nil {
 		return err
 	}
-	return os.Remove(c.snapshotPath)
+	// the snapshot is already removed if another replica waits for the cleanup of the extension
+	if err := os.Remove(c.snapshotPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// installPath returns the directory that receives the extension files
+func (c *extensionContext) installPath() (string, error) {
+	installPath, err := joinRelative(c.outputPath, c.destination)
+	if err != nil {
+		return "", fmt.Errorf("destination path %s must be relative to the extensions directory", c.destination)
+	}
+	return installPath, nil
 }
 
-func (c *extensionContext) moveSourceFiles(revisions []string, tempDir string) (sourcesSnapshot, error) {
+// joinRelative joins the base directory with the relative path and fails if the result is outside of the base
+func joinRelative(base string, rel string) (string, error) {
+	if rel == "" {
+		return base, nil
+	}
+	clean := filepath.Clean(rel)
+	if filepath.IsAbs(clean) || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("path %s is not relative", rel)
+	}
+	return filepath.Join(base, clean), nil
+}
+
+func (c *extensionContext) moveSourceFiles(ctx context.Context, revisions []string, tempDir string) (_ sourcesSnapshot, err error) {
+	_, span := tracing.Start(ctx, "moveSourceFiles", tracing.ExtensionName.String(c.name))
+	defer func() { tracing.End(span, err) }()
+
 	snapshot := sourcesSnapshot{Revisions: revisions}
+	installPath, err := c.installPath()
+	if err != nil {
+		return sourcesSnapshot{}, err
+	}
 	if err := filepath.Walk(tempDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
@@ -145,7 +347,7 @@ func (c *extensionContext) moveSourceFiles(revisions []string, tempDir string) (
 		if err != nil {
 			return err
 		}
-		targetPath := filepath.Join(c.outputPath, relPath)
+		targetPath := filepath.Join(installPath, relPath)
 		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
 			return err
 		}
@@ -157,10 +359,14 @@ func (c *extensionContext) moveSourceFiles(revisions []string, tempDir string) (
 	}); err != nil {
 		return sourcesSnapshot{}, err
 	}
+	span.SetAttributes(attribute.Int("extension.files", len(snapshot.Files)))
 	return snapshot, nil
 }
 
-func (c *extensionContext) saveSnapshot(snapshot sourcesSnapshot) error {
+func (c *extensionContext) saveSnapshot(ctx context.Context, snapshot sourcesSnapshot) (err error) {
+	_, span := tracing.Start(ctx, "saveSnapshot", tracing.ExtensionName.String(c.name))
+	defer func() { tracing.End(span, err) }()
+
 	data, err := json.MarshalIndent(snapshot, "", "  ")
 	if err != nil {
 		return err
@@ -172,7 +378,10 @@ func (c *extensionContext) saveSnapshot(snapshot sourcesSnapshot) error {
 	return nil
 }
 
-func (c *extensionContext) loadSnapshot() sourcesSnapshot {
+func (c *extensionContext) loadSnapshot(ctx context.Context) sourcesSnapshot {
+	_, span := tracing.Start(ctx, "loadSnapshot", tracing.ExtensionName.String(c.name))
+	defer span.End()
+
 	var prev sourcesSnapshot
 	if data, err := os.ReadFile(c.snapshotPath); err == nil {
 		_ = json.Unmarshal(data, &prev)
@@ -180,45 +389,225 @@ func (c *extensionContext) loadSnapshot() sourcesSnapshot {
 	return prev
 }
 
-func (c *extensionContext) downloadTo(out string) error {
-	for _, s := range c.sources {
-		switch {
-		case s.Git != nil:
-			parsedUrl, err := url.Parse(s.Git.Url)
-			if err != nil {
-				return err
-			}
-			gitURL := fmt.Sprintf("git::%s%s//resources?ref=%s", parsedUrl.Host, parsedUrl.Path, s.Git.Revision)
-			if err := getter.Get(filepath.Join(out, "resources"), gitURL); err != nil {
-				return err
-			}
-		case s.Web != nil:
-			if err := getter.Get(out, "http::"+s.Web.Url); err != nil {
-				return err
-			}
+// downloadTo downloads every source into out. Git sources are checked out at the commits resolved for them, so the
+// installed files match the resolved revisions even if a branch moved since.
+// downloadTo downloads every source into its own directory and merges the sources into the out directory. Returns an
+// error if several sources provide the same file or an extension manifest.
+func (c *extensionContext) downloadTo(ctx context.Context, out string, commits []string) error {
+	stagingDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(stagingDir)
+	}()
+
+	owners := map[string]int{}
+	for i, s := range c.sources {
+		sourceDir := filepath.Join(stagingDir, strconv.Itoa(i))
+		if err := c.downloadSourceTo(ctx, s, commits[i], sourceDir); err != nil {
+			return err
+		}
+		if err := mergeSource(sourceDir, out, i, owners); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeSource moves the files downloaded for the source with the given index into the out directory and records the
+// index of the source providing each file in owners
+func mergeSource(sourceDir string, out string, index int, owners map[string]int) error {
+	if _, err := os.Stat(sourceDir); os.IsNotExist(err) {
+		return nil
+	}
+	return filepath.Walk(sourceDir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		relPath, err := filepath.Rel(sourceDir, path)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(relPath)
+		if key == manifestJSON || key == manifestYAML {
+			key = "the extension manifest"
+		}
+		if other, ok := owners[key]; ok {
+			return fmt.Errorf("%w: sources #%d and #%d both provide %s", ErrInvalidBundle, other, index, key)
+		}
+		owners[key] = index
+		targetPath := filepath.Join(out, relPath)
+		if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+			return err
 		}
+		return moveFile(path, targetPath)
+	})
+}
+
+func (c *extensionContext) downloadSourceTo(ctx context.Context, s extensionv1.ExtensionSource, commit string, out string) (err error) {
+	ctx, span := tracing.Start(ctx, "download", c.sourceAttributes(s)...)
+	defer func() { tracing.End(span, err) }()
+	ctx, cancel := withTimeout(ctx, c.options.DownloadTimeout)
+	defer cancel()
+
+	switch {
+	case s.Type == extensionv1.SourceTypeGit && s.Git != nil:
+		return downloadGitBundle(ctx, s.Git, commit, out)
+	case s.Type == extensionv1.SourceTypeWeb && s.Web != nil:
+		webURL, err := WebSourceURL(s.Web)
+		if err != nil {
+			return err
+		}
+		return getter.Get(out, "http::"+webURL, getter.WithContext(ctx))
 	}
 	return nil
 }
 
-func (c *extensionContext) resolveRevisions() ([]string, error) {
+// resolveRevisions returns the sorted revisions of the sources and configuration, and the commit resolved for every
+// source, empty for web sources
+func (c *extensionContext) resolveRevisions(ctx context.Context) ([]string, []string, error) {
 	var res []string
-	for _, s := range c.sources {
-		switch {
-		case s.Git != nil:
-			sha, err := git.LsRemote(s.Git.Url, s.Git.Revision)
-			if err != nil {
-				return nil, err
-			}
-			res = append(res, fmt.Sprintf("%s#%s", s.Git.Url, sha))
-		case s.Web != nil:
-			res = append(res, s.Web.Url)
+	commits := make([]string, len(c.sources))
+	for i, s := range c.sources {
+		revision, commit, err := c.resolveRevision(ctx, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if revision != "" {
+			res = append(res, revision)
 		}
+		commits[i] = commit
+	}
+	if c.options.Config != nil {
+		revision, err := contentRevision("config", c.options.Config)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, revision)
+	}
+	if len(c.options.Files) > 0 {
+		revision, err := contentRevision("files", c.options.Files)
+		if err != nil {
+			return nil, nil, err
+		}
+		res = append(res, revision)
+	}
+	if c.options.Version != "" {
+		res = append(res, "version#"+c.options.Version)
 	}
 	sort.Slice(res, func(i, j int) bool {
 		return res[i] < res[j]
 	})
-	return res, nil
+	return res, commits, nil
+}
+
+func (c *extensionContext) resolveRevision(ctx context.Context, s extensionv1.ExtensionSource) (revision string, commit string, err error) {
+	ctx, span := tracing.Start(ctx, "resolveRevision", c.sourceAttributes(s)...)
+	defer func() {
+		span.SetAttributes(tracing.SourceRevision.String(revision))
+		tracing.End(span, err)
+	}()
+	ctx, cancel := withTimeout(ctx, c.options.ResolveTimeout)
+	defer cancel()
+
+	switch {
+	case s.Type == extensionv1.SourceTypeGit && s.Git != nil:
+		sha, err := git.LsRemoteContext(ctx, s.Git.Url, s.Git.Revision)
+		if err != nil {
+			return "", "", err
+		}
+		return fmt.Sprintf("%s#%s", s.Git.Url, sha), sha, nil
+	case s.Type == extensionv1.SourceTypeWeb && s.Web != nil:
+		if s.Web.Checksum != "" {
+			return fmt.Sprintf("%s#%s", s.Web.Url, s.Web.Checksum), "", nil
+		}
+		return s.Web.Url, "", nil
+	}
+	return "", "", nil
+}
+
+// withTimeout returns a context that is cancelled after the timeout, or a cancellable context if the timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// sourceAttributes returns the span attributes identifying the extension source
+func (c *extensionContext) sourceAttributes(s extensionv1.ExtensionSource) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{tracing.ExtensionName.String(c.name), tracing.SourceType.String(string(s.Type))}
+	switch {
+	case s.Type == extensionv1.SourceTypeGit && s.Git != nil:
+		attrs = append(attrs, tracing.SourceHost.String(tracing.Host(s.Git.Url)))
+		if s.Git.Revision != "" {
+			attrs = append(attrs, tracing.SourceRevision.String(s.Git.Revision))
+		}
+	case s.Type == extensionv1.SourceTypeWeb && s.Web != nil:
+		attrs = append(attrs, tracing.SourceHost.String(tracing.Host(s.Web.Url)))
+	}
+	return attrs
+}
+
+// WebSourceURL returns the source URL with the checksum query parameter verified by go-getter
+func WebSourceURL(source *extensionv1.WebSource) (string, error) {
+	if source.Checksum == "" {
+		return source.Url, nil
+	}
+	parsedURL, err := url.Parse(source.Url)
+	if err != nil {
+		return "", err
+	}
+	query := parsedURL.Query()
+	query.Set("checksum", source.Checksum)
+	parsedURL.RawQuery = query.Encode()
+	return parsedURL.String(), nil
+}
+
+// downloadGitBundle clones the repository and moves the extension bundle entries located in the source path into
+// the out directory
+func downloadGitBundle(ctx context.Context, source *extensionv1.GitSource, commit string, out string) error {
+	repoDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.RemoveAll(repoDir)
+	}()
+
+	// go-getter requires the destination directory to not exist
+	repoPath := filepath.Join(repoDir, "repo")
+	if err := git.CheckoutContext(ctx, source.Url, commit, repoPath); err != nil {
+		return err
+	}
+	bundleRoot, err := joinRelative(repoPath, source.Path)
+	if err != nil {
+		return fmt.Errorf("repository path %s must be relative to the repository root", source.Path)
+	}
+	for _, entry := range gitBundleEntries {
+		src := filepath.Join(bundleRoot, entry)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := filepath.Walk(src, func(path string, info fs.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			relPath, err := filepath.Rel(bundleRoot, path)
+			if err != nil {
+				return err
+			}
+			targetPath := filepath.Join(out, relPath)
+			if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+				return err
+			}
+			return moveFile(path, targetPath)
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func moveFile(src string, dst string) error {
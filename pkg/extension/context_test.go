@@ -0,0 +1,81 @@
+package extension
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestMergeSource(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		sources []map[string]string
+		wantErr bool
+	}{
+		{name: "distinct files", sources: []map[string]string{
+			{"extension.yaml": "name: a", "resources/extension-a.js": "a"},
+			{"resources/extension-b.js": "b"},
+		}},
+		{name: "same file", sources: []map[string]string{
+			{"resources/extension.js": "a"},
+			{"resources/extension.js": "b"},
+		}, wantErr: true},
+		{name: "two manifests", sources: []map[string]string{
+			{"extension.yaml": "name: a"},
+			{"extension.json": `{"name": "b"}`},
+		}, wantErr: true},
+		{name: "empty source", sources: []map[string]string{{"resources/extension.js": "a"}, nil}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			staging, out := t.TempDir(), t.TempDir()
+			owners := map[string]int{}
+			var err error
+			for i, files := range tc.sources {
+				sourceDir := filepath.Join(staging, strconv.Itoa(i))
+				for name, content := range files {
+					p := filepath.Join(sourceDir, filepath.FromSlash(name))
+					if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+						t.Fatal(err)
+					}
+					if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				if err = mergeSource(sourceDir, out, i, owners); err != nil {
+					break
+				}
+			}
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("mergeSource() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidBundle) {
+				t.Errorf("mergeSource() error = %v, want ErrInvalidBundle", err)
+			}
+			if tc.wantErr {
+				return
+			}
+			for _, files := range tc.sources {
+				for name, content := range files {
+					if data, err := os.ReadFile(filepath.Join(out, filepath.FromSlash(name))); err != nil || string(data) != content {
+						t.Errorf("%s = %q, %v, want %q", name, data, err, content)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCompatibilityWarnings(t *testing.T) {
+	constrained := &Manifest{Name: "metrics", ArgoCDVersion: ">= 2.5"}
+	if got := compatibilityWarnings(constrained, "", []string{"bundle warning"}); len(got) != 2 {
+		t.Errorf("compatibilityWarnings() without Argo CD version = %v, want the skipped check warning", got)
+	}
+	if got := compatibilityWarnings(constrained, "v2.6.0", nil); got != nil {
+		t.Errorf("compatibilityWarnings() with Argo CD version = %v, want none", got)
+	}
+	if got := compatibilityWarnings(&Manifest{Name: "metrics"}, "", nil); got != nil {
+		t.Errorf("compatibilityWarnings() without constraint = %v, want none", got)
+	}
+}
@@ -0,0 +1,177 @@
+package extension
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+
+	"github.com/argoproj/argocd-extensions/pkg/lua"
+)
+
+const (
+	resourcesDir             = "resources"
+	healthScriptName         = "health.lua"
+	actionsDir               = "actions"
+	actionDiscoveryName      = "discovery.lua"
+	healthCustomizationKey   = "resource.customizations.health"
+	actionsCustomizationKey  = "resource.customizations.actions"
+	luaScriptExt             = ".lua"
+	defaultDiscoveryTemplate = `actions = {}
+%sreturn actions
+`
+)
+
+// resourceActions has the same structure as Argo CD resource actions customization
+type resourceActions struct {
+	ActionDiscoveryLua string                     `json:"discovery.lua,omitempty"`
+	Definitions        []resourceActionDefinition `json:"definitions,omitempty"`
+}
+
+type resourceActionDefinition struct {
+	Name      string `json:"name"`
+	ActionLua string `json:"action.lua"`
+}
+
+// resourceScripts holds Lua scripts shipped for a single group/kind
+type resourceScripts struct {
+	group   string
+	kind    string
+	health  string
+	actions map[string]string
+}
+
+// groupKindKey returns the group/kind suffix of argocd-cm resource customization keys
+func (s *resourceScripts) groupKindKey() string {
+	return fmt.Sprintf("%s_%s", s.group, s.kind)
+}
+
+// resourceCustomizations returns the argocd-cm keys generated from the health.lua and actions/*.lua files shipped
+// in the resources/<group>/<kind> directories of the installed extension
+func (c *extensionContext) resourceCustomizations() (map[string]string, error) {
+	installPath, err := c.installPath()
+	if err != nil {
+		return nil, err
+	}
+	scripts, err := loadResourceScripts(installPath, c.files)
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]string{}
+	for _, s := range scripts {
+		if s.health != "" {
+			res[fmt.Sprintf("%s.%s", healthCustomizationKey, s.groupKindKey())] = s.health
+		}
+		if len(s.actions) > 0 {
+			actions, err := s.resourceActions()
+			if err != nil {
+				return nil, err
+			}
+			res[fmt.Sprintf("%s.%s", actionsCustomizationKey, s.groupKindKey())] = actions
+		}
+	}
+	return res, nil
+}
+
+// resourceActions renders actions into argocd-cm format and generates discovery script that enables all actions
+// if the extension does not provide one
+func (s *resourceScripts) resourceActions() (string, error) {
+	var names []string
+	for name := range s.actions {
+		if name != actionDiscoveryName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	actions := resourceActions{ActionDiscoveryLua: s.actions[actionDiscoveryName]}
+	var discovery strings.Builder
+	for _, name := range names {
+		actionName := strings.TrimSuffix(name, luaScriptExt)
+		actions.Definitions = append(actions.Definitions, resourceActionDefinition{Name: actionName, ActionLua: s.actions[name]})
+		discovery.WriteString(fmt.Sprintf("actions[%q] = {}\n", actionName))
+	}
+	if actions.ActionDiscoveryLua == "" {
+		actions.ActionDiscoveryLua = fmt.Sprintf(defaultDiscoveryTemplate, discovery.String())
+	}
+	data, err := yaml.Marshal(actions)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// verifyLua runs the Lua health and action tests shipped in the extension bundle located at root
+func verifyLua(root string) error {
+	results, err := lua.RunTests(root)
+	if err != nil {
+		return fmt.Errorf("failed to run Lua tests: %v", err)
+	}
+	if failed := lua.Failed(results); len(failed) > 0 {
+		var messages []string
+		for _, r := range failed {
+			messages = append(messages, fmt.Sprintf("%s: %v", r.Name, r.Error))
+		}
+		return fmt.Errorf("%d of %d Lua tests failed: %s", len(failed), len(results), strings.Join(messages, "; "))
+	}
+	return nil
+}
+
+// loadResourceScripts reads Lua scripts from the given files located under <root>/resources/<group>/<kind>
+func loadResourceScripts(root string, files []string) ([]*resourceScripts, error) {
+	byGroupKind := map[string]*resourceScripts{}
+	for _, file := range files {
+		if filepath.Ext(file) != luaScriptExt {
+			continue
+		}
+		relPath, err := filepath.Rel(root, file)
+		if err != nil {
+			return nil, err
+		}
+		parts := strings.Split(filepath.ToSlash(relPath), "/")
+		if len(parts) < 4 || parts[0] != resourcesDir {
+			continue
+		}
+		group, kind, rest := parts[1], parts[2], parts[3:]
+
+		var health bool
+		var actionName string
+		switch {
+		case len(rest) == 1 && rest[0] == healthScriptName:
+			health = true
+		case len(rest) == 2 && rest[0] == actionsDir:
+			actionName = rest[1]
+		default:
+			continue
+		}
+
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		key := group + "/" + kind
+		scripts, ok := byGroupKind[key]
+		if !ok {
+			scripts = &resourceScripts{group: group, kind: kind, actions: map[string]string{}}
+			byGroupKind[key] = scripts
+		}
+		if health {
+			scripts.health = string(data)
+		} else {
+			scripts.actions[actionName] = string(data)
+		}
+	}
+
+	var res []*resourceScripts
+	for _, s := range byGroupKind {
+		res = append(res, s)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].groupKindKey() < res[j].groupKindKey()
+	})
+	return res, nil
+}
@@ -0,0 +1,67 @@
+package extension
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/antonmedv/expr"
+)
+
+// DeepLinks lists the deep links an extension adds to Argo CD
+type DeepLinks struct {
+	// Application links are merged into the argocd-cm application.links
+	Application []DeepLink `json:"application,omitempty"`
+	// Resource links are merged into the argocd-cm resource.links
+	Resource []DeepLink `json:"resource,omitempty"`
+	// Project links are merged into the argocd-cm project.links
+	Project []DeepLink `json:"project,omitempty"`
+}
+
+// DeepLink has the same structure as Argo CD deep link
+type DeepLink struct {
+	URL         string `json:"url"`
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	IconClass   string `json:"icon.class,omitempty"`
+	Condition   string `json:"if,omitempty"`
+}
+
+// Validate returns an error if any link is missing required fields or has a condition that does not compile
+func (l *DeepLinks) Validate() error {
+	for _, links := range []struct {
+		name  string
+		links []DeepLink
+	}{
+		{"application", l.Application},
+		{"resource", l.Resource},
+		{"project", l.Project},
+	} {
+		titles := map[string]bool{}
+		for i, link := range links.links {
+			if err := link.Validate(); err != nil {
+				return fmt.Errorf("%s link #%d is invalid: %v", links.name, i, err)
+			}
+			if titles[link.Title] {
+				return fmt.Errorf("%s link title %s is not unique", links.name, link.Title)
+			}
+			titles[link.Title] = true
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the link is missing required fields or has a condition that does not compile
+func (l *DeepLink) Validate() error {
+	if l.Title == "" {
+		return errors.New("title is required")
+	}
+	if l.URL == "" {
+		return errors.New("url is required")
+	}
+	if l.Condition != "" {
+		if _, err := expr.Compile(l.Condition, expr.AsBool()); err != nil {
+			return fmt.Errorf("condition %q is invalid: %v", l.Condition, err)
+		}
+	}
+	return nil
+}
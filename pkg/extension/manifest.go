@@ -0,0 +1,250 @@
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/hashicorp/go-version"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+
+	"github.com/argoproj/argocd-extensions/pkg/settings"
+)
+
+const (
+	manifestJSON = "extension.json"
+	manifestYAML = "extension.yaml"
+)
+
+// ErrIncompatible is returned when the extension does not support the running Argo CD version
+var ErrIncompatible = errors.New("extension is not compatible with the running Argo CD version")
+
+// ExtensionType describes what an extension adds to Argo CD
+type ExtensionType string
+
+const (
+	ExtensionTypeUI                    ExtensionType = "UI"
+	ExtensionTypeProxy                 ExtensionType = "Proxy"
+	ExtensionTypeResourceCustomization ExtensionType = "ResourceCustomization"
+)
+
+// Manifest holds the extension metadata stored in the extension.json or extension.yaml file at the sources root
+type Manifest struct {
+	// Name is the extension name
+	Name string `json:"name"`
+	// Version is the extension semantic version
+	Version string `json:"version"`
+	// Description is a human-readable description of the extension
+	Description string `json:"description,omitempty"`
+	// Type describes what the extension adds to Argo CD
+	Type ExtensionType `json:"type"`
+	// ArgoCDVersion is a version constraint (e.g. ">= 2.5, < 3.0") that the running Argo CD must satisfy
+	ArgoCDVersion string `json:"argocdVersion,omitempty"`
+	// Proxy declares the backend services Argo CD API server proxies the extension requests to
+	Proxy *ProxyBackend `json:"proxy,omitempty"`
+	// RBAC declares the Argo CD RBAC policy recommended for the extension
+	RBAC *RBACPolicy `json:"rbac,omitempty"`
+	// Links declares the deep links the extension adds to Argo CD
+	Links *DeepLinks `json:"links,omitempty"`
+}
+
+// RBACPolicy holds Argo CD RBAC policy lines in casbin CSV format
+type RBACPolicy struct {
+	// Policies lists permission lines, e.g. "p, role:metrics, extensions, invoke, metrics, allow"
+	Policies []string `json:"policies,omitempty"`
+	// Bindings lists role binding lines, e.g. "g, role:readonly, role:metrics"
+	Bindings []string `json:"bindings,omitempty"`
+}
+
+// Validate returns an error unless the policies only allow invoking the extension with the given name and the
+// bindings only assign roles defined by the policies
+func (p *RBACPolicy) Validate(name string) error {
+	return settings.ValidateExtensionPolicy(name, p.Policies, p.Bindings)
+}
+
+// ProxyBackend has the same structure as the backend of a proxy extension in the argocd-cm extension.config
+type ProxyBackend struct {
+	ConnectionTimeout     string         `json:"connectionTimeout,omitempty"`
+	KeepAlive             string         `json:"keepAlive,omitempty"`
+	IdleConnectionTimeout string         `json:"idleConnectionTimeout,omitempty"`
+	MaxIdleConnections    int            `json:"maxIdleConnections,omitempty"`
+	Services              []ProxyService `json:"services"`
+}
+
+// ProxyService is a backend service URL, optionally mapped to the cluster of the application
+type ProxyService struct {
+	URL     string        `json:"url"`
+	Cluster *ProxyCluster `json:"cluster,omitempty"`
+}
+
+// ProxyCluster identifies an Argo CD cluster by name or server URL
+type ProxyCluster struct {
+	Name   string `json:"name,omitempty"`
+	Server string `json:"server,omitempty"`
+}
+
+// Validate returns an error if the backend has no services or has invalid values
+func (b *ProxyBackend) Validate() error {
+	for _, d := range []struct {
+		name  string
+		value string
+	}{
+		{"connectionTimeout", b.ConnectionTimeout},
+		{"keepAlive", b.KeepAlive},
+		{"idleConnectionTimeout", b.IdleConnectionTimeout},
+	} {
+		if d.value == "" {
+			continue
+		}
+		if _, err := time.ParseDuration(d.value); err != nil {
+			return fmt.Errorf("%s %s is invalid: %v", d.name, d.value, err)
+		}
+	}
+	if len(b.Services) == 0 {
+		return errors.New("at least one service is required")
+	}
+	for i, s := range b.Services {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("service #%d url %q must be an absolute http(s) URL", i, s.URL)
+		}
+		if len(b.Services) > 1 && (s.Cluster == nil || (s.Cluster.Name == "" && s.Cluster.Server == "")) {
+			return fmt.Errorf("service #%d must specify cluster name or server when multiple services are declared", i)
+		}
+	}
+	return nil
+}
+
+// Validate returns an error if the manifest is missing required fields or has invalid values
+func (m *Manifest) Validate() error {
+	if m.Name == "" {
+		return errors.New("name is required")
+	}
+	if errs := validation.IsDNS1123Subdomain(m.Name); len(errs) > 0 {
+		return fmt.Errorf("name %s is invalid: %v", m.Name, errs)
+	}
+	if m.Version == "" {
+		return errors.New("version is required")
+	}
+	if _, err := version.NewVersion(m.Version); err != nil {
+		return fmt.Errorf("version %s is invalid: %v", m.Version, err)
+	}
+	switch m.Type {
+	case ExtensionTypeUI, ExtensionTypeProxy, ExtensionTypeResourceCustomization:
+	default:
+		return fmt.Errorf("type %q is invalid, must be one of %s, %s, %s",
+			m.Type, ExtensionTypeUI, ExtensionTypeProxy, ExtensionTypeResourceCustomization)
+	}
+	if m.ArgoCDVersion != "" {
+		if _, err := version.NewConstraint(m.ArgoCDVersion); err != nil {
+			return fmt.Errorf("argocdVersion %s is invalid: %v", m.ArgoCDVersion, err)
+		}
+	}
+	if m.Proxy != nil {
+		if err := m.Proxy.Validate(); err != nil {
+			return fmt.Errorf("proxy is invalid: %v", err)
+		}
+	}
+	if m.RBAC != nil {
+		if err := m.RBAC.Validate(m.Name); err != nil {
+			return fmt.Errorf("rbac is invalid: %v", err)
+		}
+	}
+	if m.Links != nil {
+		if err := m.Links.Validate(); err != nil {
+			return fmt.Errorf("links are invalid: %v", err)
+		}
+	}
+	return nil
+}
+
+// compatibilityWarnings returns the warnings with a note that the Argo CD version constraint of the manifest has not
+// been checked if the running Argo CD version is unknown
+func compatibilityWarnings(manifest *Manifest, argocdVersion string, warnings []string) []string {
+	res := append([]string{}, warnings...)
+	if manifest != nil && manifest.ArgoCDVersion != "" && argocdVersion == "" {
+		res = append(res, fmt.Sprintf("argocdVersion constraint %q is not checked since the running Argo CD version is "+
+			"unknown, set --argocd-version or ARGOCD_VERSION", manifest.ArgoCDVersion))
+	}
+	if len(res) == 0 {
+		return nil
+	}
+	return res
+}
+
+// CheckCompatibility returns ErrIncompatible if the given Argo CD version does not satisfy the manifest constraint.
+// An empty Argo CD version skips the check.
+func (m *Manifest) CheckCompatibility(argocdVersion string) error {
+	if m.ArgoCDVersion == "" || argocdVersion == "" {
+		return nil
+	}
+	constraints, err := version.NewConstraint(m.ArgoCDVersion)
+	if err != nil {
+		return err
+	}
+	v, err := version.NewVersion(argocdVersion)
+	if err != nil {
+		return fmt.Errorf("failed to parse Argo CD version %s: %v", argocdVersion, err)
+	}
+	if !constraints.Check(v) {
+		return fmt.Errorf("%w: %s requires Argo CD %s, running %s", ErrIncompatible, m.Name, m.ArgoCDVersion, argocdVersion)
+	}
+	return nil
+}
+
+// loadManifest reads and validates the manifest file in the given directory and removes it so it is not
+// installed along with the extension files. Returns nil if the directory has no manifest.
+func loadManifest(dir string) (*Manifest, error) {
+	manifest, manifestPath, err := readManifest(dir)
+	if err != nil || manifest == nil {
+		return nil, err
+	}
+	if err := os.Remove(manifestPath); err != nil {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+// ReadManifest reads and validates the manifest file in the given directory. Returns nil if the directory has no
+// manifest.
+func ReadManifest(dir string) (*Manifest, error) {
+	manifest, _, err := readManifest(dir)
+	return manifest, err
+}
+
+// readManifest returns the parsed manifest and the path of the manifest file
+func readManifest(dir string) (*Manifest, string, error) {
+	var found []string
+	for _, name := range []string{manifestJSON, manifestYAML} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			found = append(found, name)
+		} else if !os.IsNotExist(err) {
+			return nil, "", err
+		}
+	}
+	switch len(found) {
+	case 0:
+		return nil, "", nil
+	case 1:
+	default:
+		return nil, "", fmt.Errorf("only one of %s and %s is allowed", manifestJSON, manifestYAML)
+	}
+
+	manifestPath := filepath.Join(dir, found[0])
+	data, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, "", err
+	}
+	var manifest Manifest
+	if err := yaml.UnmarshalStrict(data, &manifest); err != nil {
+		return nil, "", fmt.Errorf("failed to parse %s: %v", found[0], err)
+	}
+	if err := manifest.Validate(); err != nil {
+		return nil, "", fmt.Errorf("invalid %s: %v", found[0], err)
+	}
+	return &manifest, manifestPath, nil
+}
@@ -0,0 +1,93 @@
+package extension
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+)
+
+// manifestsDir is the bundle directory holding Kubernetes objects that support the extension
+const manifestsDir = "manifests"
+
+// loadObjects parses the Kubernetes objects stored in YAML and JSON files of the manifests directory and removes the
+// directory so it is not installed along with the extension files
+func loadObjects(dir string) ([]unstructured.Unstructured, error) {
+	root := filepath.Join(dir, manifestsDir)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var paths []string
+	if err := filepath.Walk(root, func(path string, info fs.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".yaml", ".yml", ".json":
+			paths = append(paths, path)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var objects []unstructured.Unstructured
+	ids := map[string]string{}
+	for _, path := range paths {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := parseObjects(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", relPath, err)
+		}
+		for _, obj := range parsed {
+			if obj.GetAPIVersion() == "" || obj.GetKind() == "" || obj.GetName() == "" {
+				return nil, fmt.Errorf("%s: apiVersion, kind and metadata.name are required", relPath)
+			}
+			id := fmt.Sprintf("%s/%s/%s", obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName())
+			if prev, ok := ids[id]; ok {
+				return nil, fmt.Errorf("%s: %s %s is already declared in %s", relPath, obj.GetKind(), obj.GetName(), prev)
+			}
+			ids[id] = relPath
+			objects = append(objects, obj)
+		}
+	}
+	if err := os.RemoveAll(root); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// parseObjects splits the multi-document YAML or JSON data into objects, skipping empty documents
+func parseObjects(data []byte) ([]unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var res []unstructured.Unstructured
+	for {
+		obj := map[string]interface{}{}
+		if err := decoder.Decode(&obj); err != nil {
+			if err == io.EOF {
+				return res, nil
+			}
+			return nil, err
+		}
+		if len(obj) == 0 {
+			continue
+		}
+		res = append(res, unstructured.Unstructured{Object: obj})
+	}
+}
@@ -0,0 +1,45 @@
+package extension
+
+import (
+	"fmt"
+)
+
+// Settings holds Argo CD settings generated from the installed extension
+type Settings struct {
+	// ResourceCustomizations maps argocd-cm keys to the health and actions customizations shipped in the extension
+	ResourceCustomizations map[string]string
+	// ProxyExtensions lists the argocd-cm extension.config entries of the extension backends
+	ProxyExtensions []ProxyExtension
+	// RBAC holds the RBAC policy recommended by the extension
+	RBAC RBACPolicy
+	// RequiredPermissions lists the extensions resource objects the users must be allowed to invoke
+	RequiredPermissions []string
+	// Links holds the deep links declared by the extension
+	Links DeepLinks
+}
+
+// ProxyExtension has the same structure as a proxy extension entry in the argocd-cm extension.config
+type ProxyExtension struct {
+	Name    string       `json:"name"`
+	Backend ProxyBackend `json:"backend"`
+}
+
+// Settings returns Argo CD settings generated from the installed extension files
+func (c *extensionContext) Settings() (*Settings, error) {
+	customizations, err := c.resourceCustomizations()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate resource customizations: %v", err)
+	}
+	res := &Settings{ResourceCustomizations: customizations}
+	if c.manifest != nil && c.manifest.Proxy != nil {
+		res.ProxyExtensions = append(res.ProxyExtensions, ProxyExtension{Name: c.manifest.Name, Backend: *c.manifest.Proxy})
+		res.RequiredPermissions = append(res.RequiredPermissions, c.manifest.Name)
+	}
+	if c.manifest != nil && c.manifest.RBAC != nil {
+		res.RBAC = *c.manifest.RBAC
+	}
+	if c.manifest != nil && c.manifest.Links != nil {
+		res.Links = *c.manifest.Links
+	}
+	return res, nil
+}
@@ -0,0 +1,48 @@
+package extension
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// uiEntryPointRegex matches file names Argo CD loads as UI extensions
+var uiEntryPointRegex = regexp.MustCompile(`^extension(.*)\.js$`)
+
+// validateBundle checks the installed extension files and returns warnings about files Argo CD will ignore.
+// An error is returned if the bundle is empty or, for UI extensions, has no loadable entry point.
+func validateBundle(manifest *Manifest, files []string) ([]string, error) {
+	requireEntryPoint := manifest == nil || manifest.Type == ExtensionTypeUI
+	if len(files) == 0 && (requireEntryPoint || manifest.Type == ExtensionTypeResourceCustomization) {
+		return nil, errors.New("extension bundle is empty")
+	}
+
+	var warnings []string
+	var entryPoints []string
+	for _, file := range files {
+		name := filepath.Base(file)
+		if !strings.HasSuffix(name, ".js") {
+			continue
+		}
+		if !uiEntryPointRegex.MatchString(name) {
+			warnings = append(warnings, fmt.Sprintf("%s is ignored by Argo CD: UI extension file names must match %s", file, uiEntryPointRegex))
+			continue
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() == 0 {
+			return nil, fmt.Errorf("UI extension entry point %s is empty", file)
+		}
+		entryPoints = append(entryPoints, file)
+	}
+
+	if len(entryPoints) == 0 && requireEntryPoint {
+		return nil, fmt.Errorf("extension bundle has no UI entry point: at least one file must match %s", uiEntryPointRegex)
+	}
+	return warnings, nil
+}
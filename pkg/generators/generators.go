@@ -0,0 +1,299 @@
+package generators
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/yaml"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+	"github.com/argoproj/argocd-extensions/pkg/extension"
+	"github.com/argoproj/argocd-extensions/pkg/git"
+)
+
+// Params is a parameter set used to render the ArgoCDExtensionSet template
+type Params map[string]string
+
+// Generator produces parameter sets of ArgoCDExtensionSet generators. Results of Git generators are cached by
+// the resolved commit so the repository is only cloned when it changes.
+type Generator struct {
+	mu    sync.Mutex
+	cache map[string]gitCacheEntry
+}
+
+// gitCacheEntry holds the parameter sets generated from a Git repository commit
+type gitCacheEntry struct {
+	sha    string
+	params []Params
+}
+
+// Options bounds the network operations of Git generators
+type Options struct {
+	// ResolveTimeout bounds resolving the revision of the repository, zero disables the timeout
+	ResolveTimeout time.Duration
+	// DownloadTimeout bounds cloning the repository, zero disables the timeout
+	DownloadTimeout time.Duration
+}
+
+// NewGenerator creates a Generator with an empty cache
+func NewGenerator() *Generator {
+	return &Generator{cache: map[string]gitCacheEntry{}}
+}
+
+// Generate returns the parameter sets produced by the generator
+func (g *Generator) Generate(ctx context.Context, generator extensionv1.ExtensionSetGenerator, opts Options) ([]Params, error) {
+	switch {
+	case generator.List != nil && generator.Git != nil:
+		return nil, errors.New("only one of list and git generators can be set")
+	case generator.List != nil:
+		var res []Params
+		for _, element := range generator.List.Elements {
+			params := Params{}
+			for k, v := range element {
+				params[k] = v
+			}
+			res = append(res, params)
+		}
+		return res, nil
+	case generator.Git != nil:
+		return g.generateGit(ctx, generator.Git, opts)
+	}
+	return nil, errors.New("generator must specify list or git")
+}
+
+// IsGit returns true if the generator reads a Git repository
+func IsGit(generator extensionv1.ExtensionSetGenerator) bool {
+	return generator.Git != nil
+}
+
+func (g *Generator) generateGit(ctx context.Context, generator *extensionv1.GitGenerator, opts Options) ([]Params, error) {
+	if len(generator.Directories) == 0 && len(generator.Files) == 0 {
+		return nil, errors.New("git generator must specify directories or files")
+	}
+	resolveCtx, cancel := withTimeout(ctx, opts.ResolveTimeout)
+	sha, err := git.LsRemoteContext(resolveCtx, generator.RepoURL, generator.Revision)
+	cancel()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s revision %s: %v", generator.RepoURL, generator.Revision, err)
+	}
+	spec, err := json.Marshal(generator)
+	if err != nil {
+		return nil, err
+	}
+	cacheKey := string(spec)
+
+	g.mu.Lock()
+	cached, ok := g.cache[cacheKey]
+	g.mu.Unlock()
+	if ok && cached.sha == sha {
+		return copyParams(cached.params), nil
+	}
+
+	tempDir, err := os.MkdirTemp("", "")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = os.RemoveAll(tempDir)
+	}()
+	repoPath := filepath.Join(tempDir, "repo")
+	downloadCtx, cancel := withTimeout(ctx, opts.DownloadTimeout)
+	defer cancel()
+	if err := git.CheckoutContext(downloadCtx, generator.RepoURL, sha, repoPath); err != nil {
+		return nil, fmt.Errorf("failed to checkout %s: %v", generator.RepoURL, err)
+	}
+
+	dirs, files, err := listRepo(repoPath)
+	if err != nil {
+		return nil, err
+	}
+	common := Params{"repoURL": generator.RepoURL, "revision": sha}
+	var res []Params
+	directories, err := generateDirectories(repoPath, dirs, generator.Directories, common)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, directories...)
+	entries, err := generateFiles(repoPath, files, generator.Files, common)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, entries...)
+
+	g.mu.Lock()
+	g.cache[cacheKey] = gitCacheEntry{sha: sha, params: res}
+	g.mu.Unlock()
+	return copyParams(res), nil
+}
+
+// withTimeout returns a context that is cancelled after the timeout, or a cancellable context if the timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// generateDirectories returns a parameter set for each directory that matches the items and holds an extension
+// manifest
+func generateDirectories(repoPath string, dirs []string, items []extensionv1.GitDirectoryGeneratorItem, common Params) ([]Params, error) {
+	var res []Params
+	for _, dir := range dirs {
+		included := false
+		for _, item := range items {
+			matched, err := path.Match(item.Path, dir)
+			if err != nil {
+				return nil, fmt.Errorf("invalid directory pattern %s: %v", item.Path, err)
+			}
+			if matched && item.Exclude {
+				included = false
+				break
+			}
+			included = included || matched
+		}
+		if !included {
+			continue
+		}
+		manifest, err := extension.ReadManifest(filepath.Join(repoPath, filepath.FromSlash(dir)))
+		if err != nil {
+			return nil, fmt.Errorf("directory %s: %v", dir, err)
+		}
+		if manifest == nil {
+			continue
+		}
+		params := pathParams(dir, common)
+		params["extension.name"] = manifest.Name
+		params["extension.version"] = manifest.Version
+		params["extension.type"] = string(manifest.Type)
+		res = append(res, params)
+	}
+	return res, nil
+}
+
+// generateFiles returns a parameter set for each entry of the files that match the items. A file holds either a list
+// of entries or an object with the entries under the extensions key.
+func generateFiles(repoPath string, files []string, items []extensionv1.GitFileGeneratorItem, common Params) ([]Params, error) {
+	var res []Params
+	for _, file := range files {
+		matched := false
+		for _, item := range items {
+			ok, err := path.Match(item.Path, file)
+			if err != nil {
+				return nil, fmt.Errorf("invalid file pattern %s: %v", item.Path, err)
+			}
+			matched = matched || ok
+		}
+		if !matched {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(repoPath, filepath.FromSlash(file)))
+		if err != nil {
+			return nil, err
+		}
+		var content interface{}
+		if err := yaml.Unmarshal(data, &content); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", file, err)
+		}
+		entries, ok := content.([]interface{})
+		if root, isMap := content.(map[string]interface{}); isMap {
+			entries, ok = root["extensions"].([]interface{})
+		}
+		if !ok {
+			return nil, fmt.Errorf("%s must hold a list of entries or an object with an extensions list", file)
+		}
+		for i, entry := range entries {
+			fields, ok := entry.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s entry #%d must be an object", file, i)
+			}
+			params := pathParams(path.Dir(file), common)
+			if err := flatten("", fields, params); err != nil {
+				return nil, fmt.Errorf("%s entry #%d: %v", file, i, err)
+			}
+			res = append(res, params)
+		}
+	}
+	return res, nil
+}
+
+// flatten stores nested fields as parameters with dot separated names
+func flatten(prefix string, value interface{}, params Params) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			name := k
+			if prefix != "" {
+				name = prefix + "." + k
+			}
+			if err := flatten(name, item, params); err != nil {
+				return err
+			}
+		}
+	case string:
+		params[prefix] = v
+	case nil:
+		params[prefix] = ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		params[prefix] = string(data)
+	}
+	return nil
+}
+
+func pathParams(dir string, common Params) Params {
+	params := Params{"path": dir, "path.basename": path.Base(dir)}
+	for k, v := range common {
+		params[k] = v
+	}
+	return params
+}
+
+// listRepo returns the slash separated paths of all directories and files of the repository
+func listRepo(repoPath string) ([]string, []string, error) {
+	var dirs, files []string
+	err := filepath.Walk(repoPath, func(p string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		switch {
+		case info.IsDir() && info.Name() == ".git":
+			return filepath.SkipDir
+		case info.IsDir():
+			dirs = append(dirs, relPath)
+		default:
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	sort.Strings(dirs)
+	sort.Strings(files)
+	return dirs, files, err
+}
+
+func copyParams(in []Params) []Params {
+	res := make([]Params, len(in))
+	for i := range in {
+		res[i] = Params{}
+		for k, v := range in[i] {
+			res[i][k] = v
+		}
+	}
+	return res
+}
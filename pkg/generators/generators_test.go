@@ -0,0 +1,153 @@
+package generators
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func writeRepo(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGenerate(t *testing.T) {
+	list := &extensionv1.ListGenerator{Elements: []map[string]string{{"name": "metrics"}, {"name": "logs"}}}
+	for _, tc := range []struct {
+		name      string
+		generator extensionv1.ExtensionSetGenerator
+		want      []Params
+		wantErr   bool
+	}{
+		{name: "list", generator: extensionv1.ExtensionSetGenerator{List: list}, want: []Params{{"name": "metrics"}, {"name": "logs"}}},
+		{name: "both", generator: extensionv1.ExtensionSetGenerator{List: list, Git: &extensionv1.GitGenerator{}}, wantErr: true},
+		{name: "none", wantErr: true},
+		{name: "git without items", generator: extensionv1.ExtensionSetGenerator{Git: &extensionv1.GitGenerator{RepoURL: "https://git/a.git"}},
+			wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := NewGenerator().Generate(context.Background(), tc.generator, Options{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Generate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("Generate() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	// generated parameters must not alias the generator elements
+	got, err := NewGenerator().Generate(context.Background(), extensionv1.ExtensionSetGenerator{List: list}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got[0]["name"] = "changed"
+	if list.Elements[0]["name"] != "metrics" {
+		t.Errorf("Generate() result aliases the list elements")
+	}
+}
+
+func TestGenerateGitTimeout(t *testing.T) {
+	hung := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hung
+	}))
+	defer server.Close()
+	defer close(hung)
+
+	generator := extensionv1.ExtensionSetGenerator{Git: &extensionv1.GitGenerator{
+		RepoURL:     server.URL + "/extensions.git",
+		Revision:    "main",
+		Directories: []extensionv1.GitDirectoryGeneratorItem{{Path: "*"}},
+	}}
+	start := time.Now()
+	_, err := NewGenerator().Generate(context.Background(), generator, Options{ResolveTimeout: 100 * time.Millisecond})
+	if err == nil {
+		t.Fatal("Generate() of a hung remote succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Generate() returned after %s, want about the resolve timeout", elapsed)
+	}
+}
+
+func TestGenerateDirectories(t *testing.T) {
+	repo := writeRepo(t, map[string]string{
+		"extensions/metrics/extension.yaml": "name: metrics\nversion: 1.0.0\ntype: UI\n",
+		"extensions/logs/extension.yaml":    "name: logs\nversion: 2.0.0\ntype: UI\n",
+		"extensions/legacy/extension.yaml":  "name: legacy\nversion: 0.1.0\ntype: UI\n",
+		"extensions/docs/README.md":         "no manifest",
+	})
+	dirs, _, err := listRepo(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	common := Params{"repoURL": "https://git/a.git", "revision": "abc"}
+	items := []extensionv1.GitDirectoryGeneratorItem{{Path: "extensions/*"}, {Path: "extensions/legacy", Exclude: true}}
+	got, err := generateDirectories(repo, dirs, items, common)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Params{
+		{"extension.name": "logs", "extension.version": "2.0.0", "extension.type": "UI", "path": "extensions/logs",
+			"path.basename": "logs", "repoURL": "https://git/a.git", "revision": "abc"},
+		{"extension.name": "metrics", "extension.version": "1.0.0", "extension.type": "UI", "path": "extensions/metrics",
+			"path.basename": "metrics", "repoURL": "https://git/a.git", "revision": "abc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("generateDirectories() = %v, want %v", got, want)
+	}
+
+	if _, err := generateDirectories(repo, dirs, []extensionv1.GitDirectoryGeneratorItem{{Path: "["}}, common); err == nil {
+		t.Error("generateDirectories() with an invalid pattern succeeded")
+	}
+}
+
+func TestGenerateFiles(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		content string
+		want    []Params
+		wantErr bool
+	}{
+		{name: "list", content: "- name: metrics\n  config:\n    url: https://grafana\n    replicas: 2\n    labels: [a, b]\n  owner: null\n",
+			want: []Params{{"name": "metrics", "config.url": "https://grafana", "config.replicas": "2", "config.labels": `["a","b"]`,
+				"owner": "", "path": "config", "path.basename": "config"}}},
+		{name: "extensions object", content: `{"extensions": [{"name": "metrics"}, {"name": "logs"}]}`,
+			want: []Params{{"name": "metrics", "path": "config", "path.basename": "config"}, {"name": "logs", "path": "config", "path.basename": "config"}}},
+		{name: "object without extensions", content: "name: metrics\n", wantErr: true},
+		{name: "scalar entry", content: "- metrics\n", wantErr: true},
+		{name: "invalid", content: "- {", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			repo := writeRepo(t, map[string]string{"config/extensions.yaml": tc.content, "config/other.txt": "ignored"})
+			_, files, err := listRepo(repo)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := generateFiles(repo, files, []extensionv1.GitFileGeneratorItem{{Path: "config/*.yaml"}}, Params{})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("generateFiles() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("generateFiles() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
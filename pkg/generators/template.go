@@ -0,0 +1,81 @@
+package generators
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+var paramRegex = regexp.MustCompile(`{{\s*([^{}\s]+)\s*}}`)
+
+// Render returns the ArgoCDExtension produced by replacing the {{param}} references in the template string values
+// with the parameter values. Referencing an undefined parameter is an error.
+func Render(template extensionv1.ArgoCDExtensionTemplate, params Params) (*extensionv1.ArgoCDExtension, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	rendered, err := render(value, params)
+	if err != nil {
+		return nil, err
+	}
+	if data, err = json.Marshal(rendered); err != nil {
+		return nil, err
+	}
+	var res extensionv1.ArgoCDExtensionTemplate
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return &extensionv1.ArgoCDExtension{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        res.Metadata.Name,
+			Labels:      res.Metadata.Labels,
+			Annotations: res.Metadata.Annotations,
+		},
+		Spec: res.Spec,
+	}, nil
+}
+
+func render(value interface{}, params Params) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			rendered, err := render(item, params)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = rendered
+		}
+	case []interface{}:
+		for i, item := range v {
+			rendered, err := render(item, params)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = rendered
+		}
+	case string:
+		var missing []string
+		res := paramRegex.ReplaceAllStringFunc(v, func(match string) string {
+			name := paramRegex.FindStringSubmatch(match)[1]
+			param, ok := params[name]
+			if !ok {
+				missing = append(missing, name)
+			}
+			return param
+		})
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("parameter %s is not defined", missing[0])
+		}
+		return res, nil
+	}
+	return value, nil
+}
@@ -1,9 +1,13 @@
 package git
 
 import (
+	"context"
 	"fmt"
+	"net/url"
 	"regexp"
 
+	"github.com/hashicorp/go-getter"
+
 	"gopkg.in/src-d/go-git.v4"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
@@ -83,3 +87,54 @@ func LsRemote(repoURL string, revision string) (string, error) {
 	// or symbolic ref) and we were unable to resolve it to a commit SHA.
 	return "", fmt.Errorf("Unable to resolve '%s' to a commit SHA", revision)
 }
+
+// maxConcurrentLsRemote bounds the ls-remote calls running at once, including the calls abandoned by LsRemoteContext
+const maxConcurrentLsRemote = 8
+
+// lsRemoteSlots holds a token for every running ls-remote call
+var lsRemoteSlots = make(chan struct{}, maxConcurrentLsRemote)
+
+// LsRemoteContext resolves commit sha like LsRemote and gives up once the context is done. go-git cannot cancel
+// listing the remote refs, so an abandoned call keeps running in the background until the remote responds or the
+// connection fails, and holds its slot until then. A hung remote delays other calls instead of piling up goroutines.
+func LsRemoteContext(ctx context.Context, repoURL string, revision string) (string, error) {
+	if IsCommitSHA(revision) || IsTruncatedCommitSHA(revision) {
+		return revision, nil
+	}
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to resolve revision %s of %s: %w", revision, repoURL, ctx.Err())
+	case lsRemoteSlots <- struct{}{}:
+	}
+
+	type result struct {
+		sha string
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() { <-lsRemoteSlots }()
+		sha, err := LsRemote(repoURL, revision)
+		done <- result{sha, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("failed to resolve revision %s of %s: %w", revision, repoURL, ctx.Err())
+	case res := <-done:
+		return res.sha, res.err
+	}
+}
+
+// Checkout downloads the files of the Git repo at the given revision into dir, which must not exist
+func Checkout(repoURL string, revision string, dir string) error {
+	return CheckoutContext(context.Background(), repoURL, revision, dir)
+}
+
+// CheckoutContext downloads the files like Checkout and stops the download once the context is done
+func CheckoutContext(ctx context.Context, repoURL string, revision string, dir string) error {
+	parsedURL, err := url.Parse(repoURL)
+	if err != nil {
+		return err
+	}
+	return getter.Get(dir, fmt.Sprintf("git::%s%s?ref=%s", parsedURL.Host, parsedURL.Path, revision), getter.WithContext(ctx))
+}
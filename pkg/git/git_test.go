@@ -0,0 +1,62 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLsRemoteContext(t *testing.T) {
+	release := make(chan struct{})
+	var running, maxRunning int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		<-release
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2*maxConcurrentLsRemote; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			if _, err := LsRemoteContext(ctx, server.URL+"/repo.git", "main"); !errors.Is(err, context.DeadlineExceeded) {
+				t.Errorf("LsRemoteContext() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+		}()
+	}
+	wg.Wait()
+	if max := atomic.LoadInt32(&maxRunning); max > maxConcurrentLsRemote {
+		t.Errorf("%d ls-remote calls ran at once, want at most %d", max, maxConcurrentLsRemote)
+	}
+
+	// the abandoned calls release their slots once the remote responds
+	close(release)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	_, err := LsRemoteContext(ctx, server.URL+"/repo.git", "main")
+	if err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("LsRemoteContext() error = %v, want the remote error", err)
+	}
+
+	// commit SHAs are not resolved
+	sha, err := LsRemoteContext(context.Background(), server.URL+"/repo.git", "0123456")
+	if err != nil || sha != "0123456" {
+		t.Errorf("LsRemoteContext() = %s, %v, want the commit SHA", sha, err)
+	}
+}
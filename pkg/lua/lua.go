@@ -0,0 +1,233 @@
+package lua
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+const (
+	// scriptTimeout limits the execution time of a single script
+	scriptTimeout = 1 * time.Second
+
+	HealthStatusHealthy     = "Healthy"
+	HealthStatusProgressing = "Progressing"
+	HealthStatusDegraded    = "Degraded"
+	HealthStatusSuspended   = "Suspended"
+	HealthStatusMissing     = "Missing"
+	HealthStatusUnknown     = "Unknown"
+)
+
+// HealthStatus is the result of a health check script
+type HealthStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message,omitempty"`
+}
+
+// ActionDiscovery is a single action returned by an action discovery script
+type ActionDiscovery struct {
+	Name     string `json:"name"`
+	Disabled bool   `json:"disabled,omitempty"`
+}
+
+// ExecuteHealth runs the health check script against the object the same way Argo CD does
+func ExecuteHealth(obj *unstructured.Unstructured, script string) (*HealthStatus, error) {
+	value, err := execute(obj, script)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("health script returned %s, expected table", value.Type())
+	}
+	var health HealthStatus
+	if err := fromLua(table, &health); err != nil {
+		return nil, err
+	}
+	switch health.Status {
+	case HealthStatusHealthy, HealthStatusProgressing, HealthStatusDegraded, HealthStatusSuspended, HealthStatusMissing, HealthStatusUnknown:
+	default:
+		return nil, fmt.Errorf("health script returned invalid status %q", health.Status)
+	}
+	return &health, nil
+}
+
+// ExecuteAction runs the action script against the object and returns the modified object
+func ExecuteAction(obj *unstructured.Unstructured, script string) (*unstructured.Unstructured, error) {
+	value, err := execute(obj, script)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("action script returned %s, expected table", value.Type())
+	}
+	res := map[string]interface{}{}
+	if err := fromLua(table, &res); err != nil {
+		return nil, err
+	}
+	return &unstructured.Unstructured{Object: res}, nil
+}
+
+// ExecuteDiscovery runs the action discovery script against the object and returns available actions
+func ExecuteDiscovery(obj *unstructured.Unstructured, script string) ([]ActionDiscovery, error) {
+	value, err := execute(obj, script)
+	if err != nil {
+		return nil, err
+	}
+	table, ok := value.(*lua.LTable)
+	if !ok {
+		return nil, fmt.Errorf("discovery script returned %s, expected table", value.Type())
+	}
+	var res []ActionDiscovery
+	var rangeErr error
+	table.ForEach(func(key lua.LValue, value lua.LValue) {
+		action := ActionDiscovery{Name: key.String()}
+		if t, ok := value.(*lua.LTable); ok {
+			if err := fromLua(t, &action); err != nil {
+				rangeErr = err
+			}
+			action.Name = key.String()
+		}
+		res = append(res, action)
+	})
+	return res, rangeErr
+}
+
+// execute runs the script in a sandboxed Lua state with the object exposed as the "obj" global
+func execute(obj *unstructured.Unstructured, script string) (lua.LValue, error) {
+	l := newState()
+	defer l.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeout)
+	defer cancel()
+	l.SetContext(ctx)
+
+	l.SetGlobal("obj", toLua(l, obj.Object))
+	if err := l.DoString(script); err != nil {
+		return nil, err
+	}
+	value := l.Get(-1)
+	if value == lua.LNil {
+		return nil, errors.New("script returned no value")
+	}
+	return value, nil
+}
+
+// newState creates Lua state with the same standard libraries that Argo CD exposes to scripts
+func newState() *lua.LState {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{
+		{lua.LoadLibName, lua.OpenPackage},
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+		{lua.OsLibName, openSafeOs},
+	} {
+		l.Push(l.NewFunction(lib.open))
+		l.Push(lua.LString(lib.name))
+		l.Call(1, 0)
+	}
+	return l
+}
+
+// safeOsFuncs is the subset of the os library that does not touch the filesystem or processes
+var safeOsFuncs = func() map[string]lua.LGFunction {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+	defer l.Close()
+	l.Push(l.NewFunction(lua.OpenOs))
+	l.Call(0, 1)
+	full := l.Get(-1).(*lua.LTable)
+	res := map[string]lua.LGFunction{}
+	for _, name := range []string{"time", "date", "clock", "difftime"} {
+		if fn, ok := full.RawGetString(name).(*lua.LFunction); ok && fn.GFunction != nil {
+			res[name] = fn.GFunction
+		}
+	}
+	return res
+}()
+
+// openSafeOs registers the safe os library, which also allows the 'local os = require("os")' to work
+func openSafeOs(l *lua.LState) int {
+	l.Push(l.RegisterModule(lua.OsLibName, safeOsFuncs))
+	return 1
+}
+
+// toLua converts decoded JSON value into a Lua value
+func toLua(l *lua.LState, value interface{}) lua.LValue {
+	switch v := value.(type) {
+	case nil:
+		return lua.LNil
+	case bool:
+		return lua.LBool(v)
+	case string:
+		return lua.LString(v)
+	case int64:
+		return lua.LNumber(v)
+	case int:
+		return lua.LNumber(v)
+	case float64:
+		return lua.LNumber(v)
+	case []interface{}:
+		table := l.NewTable()
+		for _, item := range v {
+			table.Append(toLua(l, item))
+		}
+		return table
+	case map[string]interface{}:
+		table := l.NewTable()
+		for key, item := range v {
+			table.RawSetString(key, toLua(l, item))
+		}
+		return table
+	default:
+		return lua.LString(fmt.Sprintf("%v", v))
+	}
+}
+
+// fromLua converts Lua table into the given Go value using JSON encoding
+func fromLua(table *lua.LTable, out interface{}) error {
+	data, err := json.Marshal(fromLuaValue(table))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func fromLuaValue(value lua.LValue) interface{} {
+	switch v := value.(type) {
+	case lua.LBool:
+		return bool(v)
+	case lua.LString:
+		return string(v)
+	case lua.LNumber:
+		if float64(v) == float64(int64(v)) {
+			return int64(v)
+		}
+		return float64(v)
+	case *lua.LTable:
+		// tables with consecutive integer keys starting from one are arrays
+		if n := v.MaxN(); n > 0 {
+			arr := make([]interface{}, 0, n)
+			for i := 1; i <= n; i++ {
+				arr = append(arr, fromLuaValue(v.RawGetInt(i)))
+			}
+			return arr
+		}
+		res := map[string]interface{}{}
+		v.ForEach(func(key lua.LValue, item lua.LValue) {
+			res[key.String()] = fromLuaValue(item)
+		})
+		return res
+	default:
+		return nil
+	}
+}
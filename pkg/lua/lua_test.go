@@ -0,0 +1,115 @@
+package lua
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func newObject() *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"spec":       map[string]interface{}{"replicas": int64(2), "paused": true, "ports": []interface{}{int64(80), int64(443)}},
+	}}
+}
+
+func TestExecuteHealth(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		script  string
+		want    *HealthStatus
+		wantErr string
+	}{
+		{name: "healthy", script: `return {status = "Healthy"}`, want: &HealthStatus{Status: HealthStatusHealthy}},
+		{name: "reads object", script: `
+if obj.spec.paused then
+  return {status = "Suspended", message = obj.kind .. " has " .. obj.spec.replicas .. " replicas"}
+end
+return {status = "Healthy"}`, want: &HealthStatus{Status: HealthStatusSuspended, Message: "Widget has 2 replicas"}},
+		{name: "degraded", script: `hs = {}
+hs.status = "Degraded"
+hs.message = "port " .. obj.spec.ports[2] .. " is closed"
+return hs`, want: &HealthStatus{Status: HealthStatusDegraded, Message: "port 443 is closed"}},
+		{name: "invalid status", script: `return {status = "Broken"}`, wantErr: `invalid status "Broken"`},
+		{name: "not a table", script: `return "Healthy"`, wantErr: "expected table"},
+		{name: "no value", script: `local hs = {}`, wantErr: "no value"},
+		{name: "syntax error", script: `return {`, wantErr: "syntax error"},
+		{name: "os time", script: `local os = require("os")
+if os.time() > 0 and os.date("%Y") ~= "" then return {status = "Healthy"} end`, want: &HealthStatus{Status: HealthStatusHealthy}},
+		{name: "string and table libraries", script: `local parts = {}
+table.insert(parts, string.upper("ok"))
+return {status = "Healthy", message = table.concat(parts, ",")}`, want: &HealthStatus{Status: HealthStatusHealthy, Message: "OK"}},
+		{name: "io is not available", script: `io.open("/etc/passwd")`, wantErr: "non-table object(nil) with key 'open'"},
+		{name: "os.execute is blocked", script: `os.execute("touch /tmp/lua-test")`, wantErr: "attempt to call a non-function object"},
+		{name: "os.remove is blocked", script: `os.remove("/tmp/lua-test")`, wantErr: "attempt to call a non-function object"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ExecuteHealth(newObject(), tc.script)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("ExecuteHealth() error = %v, want error containing %q", err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ExecuteHealth() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExecuteTimeout(t *testing.T) {
+	start := time.Now()
+	_, err := ExecuteHealth(newObject(), `while true do end`)
+	if err == nil {
+		t.Fatal("ExecuteHealth() of an endless loop succeeded")
+	}
+	if elapsed := time.Since(start); elapsed > scriptTimeout+2*time.Second {
+		t.Errorf("ExecuteHealth() returned after %s, want about %s", elapsed, scriptTimeout)
+	}
+}
+
+func TestExecuteAction(t *testing.T) {
+	got, err := ExecuteAction(newObject(), `obj.spec.paused = false
+obj.spec.replicas = obj.spec.replicas + 1
+obj.metadata = {annotations = {restartedAt = "now"}}
+return obj`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"apiVersion": "example.com/v1",
+		"kind":       "Widget",
+		"metadata":   map[string]interface{}{"annotations": map[string]interface{}{"restartedAt": "now"}},
+		"spec":       map[string]interface{}{"replicas": int64(3), "paused": false, "ports": []interface{}{int64(80), int64(443)}},
+	}
+	if !reflect.DeepEqual(normalize(got.Object), normalize(want)) {
+		t.Errorf("ExecuteAction() = %v, want %v", got.Object, want)
+	}
+
+	if _, err := ExecuteAction(newObject(), `return true`); err == nil {
+		t.Error("ExecuteAction() of a script returning a boolean succeeded")
+	}
+}
+
+func TestExecuteDiscovery(t *testing.T) {
+	got, err := ExecuteDiscovery(newObject(), `local actions = {}
+actions["resume"] = {disabled = not obj.spec.paused}
+actions["restart"] = {}
+return actions`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortActions(got)
+	want := []ActionDiscovery{{Name: "restart"}, {Name: "resume"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExecuteDiscovery() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,257 @@
+package lua
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	resourcesDir       = "resources"
+	healthScriptName   = "health.lua"
+	healthTestName     = "health_test.yaml"
+	actionsDir         = "actions"
+	actionTestName     = "action_test.yaml"
+	actionDiscoveryLua = "discovery.lua"
+)
+
+// healthTests has the same structure as health_test.yaml files of Argo CD resource customizations
+type healthTests struct {
+	Tests []struct {
+		HealthStatus HealthStatus `json:"healthStatus"`
+		InputPath    string       `json:"inputPath"`
+	} `json:"tests"`
+}
+
+// actionTests has the same structure as action_test.yaml files of Argo CD resource customizations
+type actionTests struct {
+	DiscoveryTests []struct {
+		InputPath string            `json:"inputPath"`
+		Result    []ActionDiscovery `json:"result"`
+	} `json:"discoveryTests"`
+	ActionTests []struct {
+		Action             string `json:"action"`
+		InputPath          string `json:"inputPath"`
+		ExpectedOutputPath string `json:"expectedOutputPath"`
+	} `json:"actionTests"`
+}
+
+// TestResult is the outcome of a single fixture test
+type TestResult struct {
+	// Name identifies the test, e.g. argoproj.io/Rollout health testdata/healthy.yaml
+	Name string
+	// Error is nil if the test has passed
+	Error error
+}
+
+// RunTests runs health and action tests found in the resources/<group>/<kind> directories of the extension bundle
+// located at root. Test files follow the Argo CD resource customizations layout:
+//
+//	resources/<group>/<kind>/health.lua
+//	resources/<group>/<kind>/health_test.yaml
+//	resources/<group>/<kind>/actions/discovery.lua
+//	resources/<group>/<kind>/actions/<action>.lua
+//	resources/<group>/<kind>/actions/action_test.yaml
+//	resources/<group>/<kind>/testdata/*.yaml
+func RunTests(root string) ([]TestResult, error) {
+	kindDirs, err := filepath.Glob(filepath.Join(root, resourcesDir, "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(kindDirs)
+
+	var results []TestResult
+	for _, dir := range kindDirs {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			continue
+		}
+		group, kind := filepath.Base(filepath.Dir(dir)), filepath.Base(dir)
+		prefix := fmt.Sprintf("%s/%s", group, kind)
+
+		healthResults, err := runHealthTests(dir, prefix)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, healthResults...)
+
+		actionResults, err := runActionTests(filepath.Join(dir, actionsDir), prefix)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, actionResults...)
+	}
+	return results, nil
+}
+
+// Failed returns the results of failed tests
+func Failed(results []TestResult) []TestResult {
+	var res []TestResult
+	for _, r := range results {
+		if r.Error != nil {
+			res = append(res, r)
+		}
+	}
+	return res
+}
+
+func runHealthTests(dir string, prefix string) ([]TestResult, error) {
+	var tests healthTests
+	if ok, err := readYAML(filepath.Join(dir, healthTestName), &tests); err != nil || !ok {
+		return nil, err
+	}
+	script, err := os.ReadFile(filepath.Join(dir, healthScriptName))
+	if err != nil {
+		return nil, err
+	}
+
+	var results []TestResult
+	for _, test := range tests.Tests {
+		result := TestResult{Name: fmt.Sprintf("%s health %s", prefix, test.InputPath)}
+		obj, err := readObject(filepath.Join(dir, test.InputPath))
+		if err != nil {
+			return nil, err
+		}
+		health, err := ExecuteHealth(obj, string(script))
+		switch {
+		case err != nil:
+			result.Error = err
+		case *health != test.HealthStatus:
+			result.Error = fmt.Errorf("expected %s (%s), got %s (%s)",
+				test.HealthStatus.Status, test.HealthStatus.Message, health.Status, health.Message)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func runActionTests(dir string, prefix string) ([]TestResult, error) {
+	var tests actionTests
+	if ok, err := readYAML(filepath.Join(dir, actionTestName), &tests); err != nil || !ok {
+		return nil, err
+	}
+
+	var results []TestResult
+	for _, test := range tests.DiscoveryTests {
+		result := TestResult{Name: fmt.Sprintf("%s discovery %s", prefix, test.InputPath)}
+		script, err := os.ReadFile(filepath.Join(dir, actionDiscoveryLua))
+		if err != nil {
+			return nil, err
+		}
+		obj, err := readObject(filepath.Join(dir, test.InputPath))
+		if err != nil {
+			return nil, err
+		}
+		actions, err := ExecuteDiscovery(obj, string(script))
+		sortActions(actions)
+		sortActions(test.Result)
+		switch {
+		case err != nil:
+			result.Error = err
+		case !reflect.DeepEqual(normalize(actions), normalize(test.Result)):
+			result.Error = fmt.Errorf("expected actions %v, got %v", test.Result, actions)
+		}
+		results = append(results, result)
+	}
+
+	for _, test := range tests.ActionTests {
+		result := TestResult{Name: fmt.Sprintf("%s action %s %s", prefix, test.Action, test.InputPath)}
+		script, err := os.ReadFile(filepath.Join(dir, test.Action+".lua"))
+		if err != nil {
+			return nil, err
+		}
+		obj, err := readObject(filepath.Join(dir, test.InputPath))
+		if err != nil {
+			return nil, err
+		}
+		expected, err := readObject(filepath.Join(dir, test.ExpectedOutputPath))
+		if err != nil {
+			return nil, err
+		}
+		actual, err := ExecuteAction(obj, string(script))
+		switch {
+		case err != nil:
+			result.Error = err
+		case !reflect.DeepEqual(normalize(actual.Object), normalize(expected.Object)):
+			result.Error = fmt.Errorf("object does not match %s", test.ExpectedOutputPath)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+func sortActions(actions []ActionDiscovery) {
+	sort.Slice(actions, func(i, j int) bool {
+		return actions[i].Name < actions[j].Name
+	})
+}
+
+// normalize converts the value to its JSON representation and drops empty maps and lists, since Lua does not
+// distinguish between empty tables and missing values
+func normalize(value interface{}) interface{} {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value
+	}
+	var res interface{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return value
+	}
+	return dropEmpty(res)
+}
+
+func dropEmpty(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			if item = dropEmpty(item); item == nil {
+				delete(v, key)
+			} else {
+				v[key] = item
+			}
+		}
+		if len(v) == 0 {
+			return nil
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return nil
+		}
+		for i := range v {
+			v[i] = dropEmpty(v[i])
+		}
+	}
+	return value
+}
+
+func readObject(path string) (*unstructured.Unstructured, error) {
+	obj := map[string]interface{}{}
+	ok, err := readYAML(path, &obj)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("fixture %s does not exist", path)
+	}
+	return &unstructured.Unstructured{Object: obj}, nil
+}
+
+// readYAML parses the YAML file into out and returns false if the file does not exist
+func readYAML(path string, out interface{}) (bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return true, nil
+}
@@ -0,0 +1,84 @@
+package lua
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunTests(t *testing.T) {
+	results, err := RunTests("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"batch/CronJob discovery testdata/cronjob.yaml",
+		"batch/CronJob action suspend testdata/cronjob.yaml",
+		"cert-manager.io/Certificate health testdata/progressing.yaml",
+		"cert-manager.io/Certificate health testdata/degraded.yaml",
+		"cert-manager.io/Certificate health testdata/healthy.yaml",
+	}
+	if len(results) != len(want) {
+		t.Fatalf("RunTests() returned %d results, want %d: %v", len(results), len(want), results)
+	}
+	for i, r := range results {
+		if r.Name != want[i] {
+			t.Errorf("result #%d = %s, want %s", i, r.Name, want[i])
+		}
+		if r.Error != nil {
+			t.Errorf("%s failed: %v", r.Name, r.Error)
+		}
+	}
+}
+
+func TestRunTestsFailures(t *testing.T) {
+	copyDir := func(src, dst string) {
+		err := filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			rel, _ := filepath.Rel(src, p)
+			if info.IsDir() {
+				return os.MkdirAll(filepath.Join(dst, rel), 0755)
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return os.WriteFile(filepath.Join(dst, rel), data, 0644)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := t.TempDir()
+	copyDir("testdata", root)
+	certDir := filepath.Join(root, "resources", "cert-manager.io", "Certificate")
+	cronJobDir := filepath.Join(root, "resources", "batch", "CronJob", "actions")
+	for path, content := range map[string]string{
+		// reports every certificate as healthy
+		filepath.Join(certDir, "health.lua"): `return {status = "Healthy"}`,
+		// forgets to set the field
+		filepath.Join(cronJobDir, "suspend.lua"): `return obj`,
+		// offers no actions
+		filepath.Join(cronJobDir, "discovery.lua"): `return {}`,
+	} {
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	results, err := RunTests(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed := Failed(results); len(failed) != 5 {
+		t.Errorf("Failed() = %v, want 5 failures", failed)
+	}
+
+	if err := os.Remove(filepath.Join(certDir, "testdata", "healthy.yaml")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RunTests(root); err == nil {
+		t.Error("RunTests() with a missing fixture succeeded")
+	}
+}
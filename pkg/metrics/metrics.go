@@ -0,0 +1,206 @@
+package metrics
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+const namespace = "argocd_extension"
+
+var (
+	extensionLabels = []string{"namespace", "name"}
+
+	reconcileTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reconcile_total",
+		Help:      "Number of extension reconciliations by the reason of the resulting Ready condition.",
+	}, append(extensionLabels, "result"))
+
+	ready = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "ready",
+		Help:      "Whether the extension is Ready (1) or not (0).",
+	}, extensionLabels)
+
+	resolveDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "resolve_duration_seconds",
+		Help:      "Duration of resolving the revisions of the extension sources.",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 10),
+	}, extensionLabels)
+
+	downloadDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "download_duration_seconds",
+		Help:      "Duration of downloading the extension sources.",
+		Buckets:   prometheus.ExponentialBuckets(0.1, 2, 12),
+	}, extensionLabels)
+
+	downloadedBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "downloaded_bytes_total",
+		Help:      "Number of bytes downloaded from the extension sources.",
+	}, extensionLabels)
+
+	installedFiles = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "installed_files",
+		Help:      "Number of files installed for the extension.",
+	}, extensionLabels)
+
+	// reconcileResults tracks the result label values of each extension so its series can be deleted
+	reconcileResults   = map[[2]string]map[string]bool{}
+	reconcileResultsMu sync.Mutex
+
+	lastSync = &lastSyncCollector{
+		desc: prometheus.NewDesc(namespace+"_seconds_since_last_sync",
+			"Seconds since the extension was last successfully synced.", extensionLabels, nil),
+		times: map[[2]string]time.Time{},
+	}
+)
+
+// Register registers the extension collectors with the controller-runtime metrics registry. The disk usage is
+// reported for the given extensions directory.
+func Register(extensionsPath string) {
+	metrics.Registry.MustRegister(
+		reconcileTotal,
+		ready,
+		resolveDuration,
+		downloadDuration,
+		downloadedBytes,
+		installedFiles,
+		lastSync,
+		&diskUsageCollector{
+			path: extensionsPath,
+			desc: prometheus.NewDesc(namespace+"_disk_usage_bytes", "Size of the files in the extensions directory.", nil, nil),
+		},
+	)
+}
+
+// ObserveReconcile records the reconciliation outcome. A Ready extension is recorded as successfully synced.
+func ObserveReconcile(extNamespace string, name string, reason string, isReady bool) {
+	reconcileTotal.WithLabelValues(extNamespace, name, reason).Inc()
+	reconcileResultsMu.Lock()
+	key := [2]string{extNamespace, name}
+	if reconcileResults[key] == nil {
+		reconcileResults[key] = map[string]bool{}
+	}
+	reconcileResults[key][reason] = true
+	reconcileResultsMu.Unlock()
+	if isReady {
+		ready.WithLabelValues(extNamespace, name).Set(1)
+		lastSync.set(extNamespace, name, time.Now())
+	} else {
+		ready.WithLabelValues(extNamespace, name).Set(0)
+	}
+}
+
+// ObserveResolve records the duration of resolving the extension sources revisions
+func ObserveResolve(extNamespace string, name string, duration time.Duration) {
+	resolveDuration.WithLabelValues(extNamespace, name).Observe(duration.Seconds())
+}
+
+// ObserveDownload records the duration and size of downloading the extension sources
+func ObserveDownload(extNamespace string, name string, duration time.Duration, bytes int64) {
+	downloadDuration.WithLabelValues(extNamespace, name).Observe(duration.Seconds())
+	downloadedBytes.WithLabelValues(extNamespace, name).Add(float64(bytes))
+}
+
+// SetInstalledFiles records the number of installed extension files
+func SetInstalledFiles(extNamespace string, name string, count int) {
+	installedFiles.WithLabelValues(extNamespace, name).Set(float64(count))
+}
+
+// DeleteExtension removes the series of a deleted extension
+func DeleteExtension(extNamespace string, name string) {
+	labels := prometheus.Labels{"namespace": extNamespace, "name": name}
+	reconcileResultsMu.Lock()
+	key := [2]string{extNamespace, name}
+	for reason := range reconcileResults[key] {
+		reconcileTotal.DeleteLabelValues(extNamespace, name, reason)
+	}
+	delete(reconcileResults, key)
+	reconcileResultsMu.Unlock()
+	for _, vec := range []*prometheus.GaugeVec{ready, installedFiles} {
+		vec.Delete(labels)
+	}
+	for _, vec := range []*prometheus.HistogramVec{resolveDuration, downloadDuration} {
+		vec.Delete(labels)
+	}
+	downloadedBytes.Delete(labels)
+	lastSync.delete(extNamespace, name)
+}
+
+// lastSyncCollector reports the time since the last successful sync, computed at scrape time
+type lastSyncCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.Mutex
+	times map[[2]string]time.Time
+}
+
+func (c *lastSyncCollector) set(extNamespace string, name string, t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.times[[2]string{extNamespace, name}] = t
+}
+
+func (c *lastSyncCollector) delete(extNamespace string, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.times, [2]string{extNamespace, name})
+}
+
+func (c *lastSyncCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *lastSyncCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key, t := range c.times {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(t).Seconds(), key[0], key[1])
+	}
+}
+
+// diskUsageCollector reports the size of the extensions directory, computed at scrape time
+type diskUsageCollector struct {
+	path string
+	desc *prometheus.Desc
+}
+
+func (c *diskUsageCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *diskUsageCollector) Collect(ch chan<- prometheus.Metric) {
+	size, err := DirSize(c.path)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.desc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(size))
+}
+
+// DirSize returns the total size of the regular files in the directory tree. A missing directory has no size.
+func DirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err != nil {
+			if path == dir && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,220 @@
+package migrate
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/yaml"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+const (
+	resourceCustomizationsKey = "resource.customizations"
+	healthKeyPrefix           = "resource.customizations.health."
+	actionsKeyPrefix          = "resource.customizations.actions."
+	finalizerName             = "extensions-finalizer.argocd.argoproj.io"
+)
+
+// Options configures the generated extension bundles and ArgoCDExtension objects
+type Options struct {
+	// OutputDir is the directory that receives one bundle directory per group/kind
+	OutputDir string
+	// Version is the version of the generated extensions
+	Version string
+	// RepoURL is the Git repository the bundles will be pushed to
+	RepoURL string
+	// Revision is the Git revision of the generated extension sources
+	Revision string
+	// RepoPath is the directory of the Git repository that holds the bundle directories
+	RepoPath string
+}
+
+// Bundle is a resource customization extension generated for a single group/kind
+type Bundle struct {
+	Group  string
+	Kind   string
+	Health string
+	// Actions holds the actions customization in argocd-cm format, including discovery.lua
+	Actions string
+}
+
+// Name returns the DNS compatible extension name
+func (b *Bundle) Name() string {
+	return strings.ToLower(fmt.Sprintf("%s.%s", b.Kind, b.Group))
+}
+
+// resourceActions has the same structure as Argo CD resource actions customization
+type resourceActions struct {
+	ActionDiscoveryLua string `json:"discovery.lua,omitempty"`
+	Definitions        []struct {
+		Name      string `json:"name"`
+		ActionLua string `json:"action.lua"`
+	} `json:"definitions,omitempty"`
+}
+
+// legacyCustomization is a single entry of the legacy resource.customizations key
+type legacyCustomization struct {
+	HealthLua string `json:"health.lua,omitempty"`
+	Actions   string `json:"actions,omitempty"`
+}
+
+// ReadConfigMap parses argocd-cm manifest file
+func ReadConfigMap(path string) (*corev1.ConfigMap, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cm corev1.ConfigMap
+	if err := yaml.Unmarshal(data, &cm); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return &cm, nil
+}
+
+// Split groups the health and actions customizations of argocd-cm by group/kind. Both the legacy
+// resource.customizations key and the resource.customizations.<type>.<group_kind> keys are supported.
+// Returns warnings about customizations that could not be migrated.
+func Split(cm *corev1.ConfigMap) ([]*Bundle, []string, error) {
+	bundles := map[string]*Bundle{}
+	var warnings []string
+	get := func(group, kind string) *Bundle {
+		key := group + "/" + kind
+		if b, ok := bundles[key]; ok {
+			return b
+		}
+		b := &Bundle{Group: group, Kind: kind}
+		bundles[key] = b
+		return b
+	}
+
+	if data, ok := cm.Data[resourceCustomizationsKey]; ok {
+		legacy := map[string]legacyCustomization{}
+		if err := yaml.Unmarshal([]byte(data), &legacy); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse %s: %v", resourceCustomizationsKey, err)
+		}
+		for groupKind, c := range legacy {
+			parts := strings.Split(groupKind, "/")
+			if len(parts) != 2 || parts[0] == "" {
+				warnings = append(warnings, fmt.Sprintf("%s: %s is not a group/kind of a custom resource, skipping", resourceCustomizationsKey, groupKind))
+				continue
+			}
+			if c.HealthLua == "" && c.Actions == "" {
+				continue
+			}
+			b := get(parts[0], parts[1])
+			b.Health, b.Actions = c.HealthLua, c.Actions
+		}
+	}
+
+	for key, value := range cm.Data {
+		var prefix string
+		switch {
+		case strings.HasPrefix(key, healthKeyPrefix):
+			prefix = healthKeyPrefix
+		case strings.HasPrefix(key, actionsKeyPrefix):
+			prefix = actionsKeyPrefix
+		default:
+			continue
+		}
+		groupKind := strings.TrimPrefix(key, prefix)
+		i := strings.LastIndex(groupKind, "_")
+		if i <= 0 || i == len(groupKind)-1 {
+			warnings = append(warnings, fmt.Sprintf("%s is not a group_kind key of a custom resource, skipping", key))
+			continue
+		}
+		b := get(groupKind[:i], groupKind[i+1:])
+		if prefix == healthKeyPrefix {
+			b.Health = value
+		} else {
+			b.Actions = value
+		}
+	}
+
+	var res []*Bundle
+	for _, b := range bundles {
+		res = append(res, b)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name() < res[j].Name()
+	})
+	sort.Strings(warnings)
+	return res, warnings, nil
+}
+
+// WriteBundle writes the extension manifest and Lua scripts of the bundle into <output>/<name>
+func WriteBundle(b *Bundle, opts Options) error {
+	dir := filepath.Join(opts.OutputDir, b.Name())
+	kindDir := filepath.Join(dir, "resources", b.Group, b.Kind)
+	files := map[string]string{}
+
+	manifest, err := yaml.Marshal(map[string]interface{}{
+		"name":        b.Name(),
+		"version":     opts.Version,
+		"description": fmt.Sprintf("Resource customizations of %s/%s migrated from argocd-cm", b.Group, b.Kind),
+		"type":        "ResourceCustomization",
+	})
+	if err != nil {
+		return err
+	}
+	files[filepath.Join(dir, "extension.yaml")] = string(manifest)
+
+	if b.Health != "" {
+		files[filepath.Join(kindDir, "health.lua")] = b.Health
+	}
+	if b.Actions != "" {
+		var actions resourceActions
+		if err := yaml.Unmarshal([]byte(b.Actions), &actions); err != nil {
+			return fmt.Errorf("failed to parse actions of %s/%s: %v", b.Group, b.Kind, err)
+		}
+		if actions.ActionDiscoveryLua != "" {
+			files[filepath.Join(kindDir, "actions", "discovery.lua")] = actions.ActionDiscoveryLua
+		}
+		for _, d := range actions.Definitions {
+			if d.Name == "" || strings.ContainsAny(d.Name, `/\`) || d.Name == "discovery" {
+				return fmt.Errorf("action name %q of %s/%s can't be used as a file name", d.Name, b.Group, b.Kind)
+			}
+			files[filepath.Join(kindDir, "actions", d.Name+".lua")] = d.ActionLua
+		}
+	}
+
+	for path, content := range files {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Extension returns the ArgoCDExtension that installs the bundle from the Git repository
+func Extension(b *Bundle, namespace string, opts Options) *extensionv1.ArgoCDExtension {
+	return &extensionv1.ArgoCDExtension{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: extensionv1.GroupVersion.String(),
+			Kind:       "ArgoCDExtension",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:       b.Name(),
+			Namespace:  namespace,
+			Finalizers: []string{finalizerName},
+		},
+		Spec: extensionv1.ArgoCDExtensionSpec{
+			Sources: []extensionv1.ExtensionSource{{
+				Type: extensionv1.SourceTypeGit,
+				Git: &extensionv1.GitSource{
+					Url:      opts.RepoURL,
+					Revision: opts.Revision,
+					Path:     filepath.ToSlash(filepath.Join(opts.RepoPath, b.Name())),
+				},
+			}},
+		},
+	}
+}
@@ -0,0 +1,92 @@
+package migrate
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestSplit(t *testing.T) {
+	cm := &corev1.ConfigMap{Data: map[string]string{
+		"url": "https://argocd",
+		"resource.customizations": `
+example.com/Widget:
+  health.lua: widget health
+apps/Deployment:
+  actions: deployment actions
+Service:
+  health.lua: service health
+`,
+		"resource.customizations.health.example.com_Widget":   "widget health override",
+		"resource.customizations.actions.example.com_Widget":  "widget actions",
+		"resource.customizations.health.argoproj.io_Rollout":  "rollout health",
+		"resource.customizations.health.ConfigMap":            "invalid",
+		"resource.customizations.ignoreDifferences.apps_Test": "not migrated",
+	}}
+	bundles, warnings, err := Split(cm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []Bundle
+	for _, b := range bundles {
+		got = append(got, *b)
+	}
+	want := []Bundle{
+		{Group: "apps", Kind: "Deployment", Actions: "deployment actions"},
+		{Group: "argoproj.io", Kind: "Rollout", Health: "rollout health"},
+		{Group: "example.com", Kind: "Widget", Health: "widget health override", Actions: "widget actions"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Split() = %+v, want %+v", got, want)
+	}
+	if len(warnings) != 2 {
+		t.Errorf("warnings = %v, want 2 warnings", warnings)
+	}
+
+	if _, _, err := Split(&corev1.ConfigMap{Data: map[string]string{"resource.customizations": "- invalid"}}); err == nil {
+		t.Error("Split() of an invalid resource.customizations key succeeded")
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	b := &Bundle{Group: "example.com", Kind: "Widget", Health: "hs = {}", Actions: `
+discovery.lua: actions = {}
+definitions:
+- name: restart
+  action.lua: return obj
+`}
+	opts := Options{OutputDir: t.TempDir(), Version: "1.0.0", RepoURL: "https://git/a.git", Revision: "main", RepoPath: "extensions"}
+	if err := WriteBundle(b, opts); err != nil {
+		t.Fatal(err)
+	}
+	kindDir := filepath.Join(opts.OutputDir, "widget.example.com", "resources", "example.com", "Widget")
+	for path, want := range map[string]string{
+		filepath.Join(kindDir, "health.lua"):               "hs = {}",
+		filepath.Join(kindDir, "actions", "discovery.lua"): "actions = {}",
+		filepath.Join(kindDir, "actions", "restart.lua"):   "return obj",
+	} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != want {
+			t.Errorf("%s = %q, want %q", path, data, want)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(opts.OutputDir, "widget.example.com", "extension.yaml")); err != nil {
+		t.Error(err)
+	}
+
+	ext := Extension(b, "argocd", opts)
+	if ext.Name != "widget.example.com" || ext.Spec.Sources[0].Git.Path != "extensions/widget.example.com" {
+		t.Errorf("Extension() = %s with path %s", ext.Name, ext.Spec.Sources[0].Git.Path)
+	}
+
+	b.Actions = "definitions:\n- name: ../escape\n  action.lua: return obj\n"
+	if err := WriteBundle(b, opts); err == nil {
+		t.Error("WriteBundle() with an action name holding a path separator succeeded")
+	}
+}
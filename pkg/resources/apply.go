@@ -0,0 +1,151 @@
+package resources
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+const (
+	// FieldOwner is the server-side apply field manager of the applied objects
+	FieldOwner = "argocd-extensions"
+
+	// ExtensionNameLabel holds the name of the extension that owns the object
+	ExtensionNameLabel = "argocd-extensions.argoproj.io/extension"
+	// ExtensionNamespaceLabel holds the namespace of the extension that owns the object
+	ExtensionNamespaceLabel = "argocd-extensions.argoproj.io/extension-namespace"
+)
+
+// applyOrder lists the kinds that other objects commonly depend on and must be applied first
+var applyOrder = map[schema.GroupKind]int{
+	{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}: -2,
+	{Kind: "Namespace"}: -1,
+}
+
+// Applier applies and prunes Kubernetes objects on behalf of extensions
+type Applier struct {
+	client client.Client
+}
+
+// NewApplier creates an Applier that uses the given client
+func NewApplier(c client.Client) *Applier {
+	return &Applier{client: c}
+}
+
+// Apply server-side applies the objects labeled with the owner extension and prunes the previously applied objects
+// that are no longer desired. Namespaced objects without a namespace are applied in the owner namespace. Returns the
+// inventory of applied objects along with their health; if applying fails the inventory also keeps the previous
+// objects so they can still be pruned later.
+func (a *Applier) Apply(ctx context.Context, owner types.NamespacedName, objects []unstructured.Unstructured, previous []extensionv1.ResourceStatus) ([]extensionv1.ResourceStatus, error) {
+	desired := make([]*unstructured.Unstructured, len(objects))
+	for i := range objects {
+		desired[i] = objects[i].DeepCopy()
+	}
+	sort.SliceStable(desired, func(i, j int) bool {
+		return applyOrder[desired[i].GroupVersionKind().GroupKind()] < applyOrder[desired[j].GroupVersionKind().GroupKind()]
+	})
+
+	var inventory []extensionv1.ResourceStatus
+	applied := map[string]bool{}
+	for _, obj := range desired {
+		if err := a.apply(ctx, owner, obj); err != nil {
+			return keepPrevious(inventory, previous, applied), fmt.Errorf("failed to apply %s %s: %v", obj.GetKind(), obj.GetName(), err)
+		}
+		status := toResourceStatus(obj)
+		status.Health, status.Message = Health(obj)
+		inventory = append(inventory, status)
+		applied[resourceKey(status)] = true
+	}
+
+	var stale []extensionv1.ResourceStatus
+	for _, r := range previous {
+		if !applied[resourceKey(r)] {
+			stale = append(stale, r)
+		}
+	}
+	remaining, err := a.Prune(ctx, owner, stale)
+	return append(inventory, remaining...), err
+}
+
+// Prune deletes the objects that are still labeled with the owner extension. Returns the objects that could not
+// be deleted.
+func (a *Applier) Prune(ctx context.Context, owner types.NamespacedName, resources []extensionv1.ResourceStatus) ([]extensionv1.ResourceStatus, error) {
+	for i, r := range resources {
+		obj := &unstructured.Unstructured{}
+		obj.SetGroupVersionKind(schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind})
+		err := a.client.Get(ctx, types.NamespacedName{Namespace: r.Namespace, Name: r.Name}, obj)
+		switch {
+		case apierrors.IsNotFound(err) || meta.IsNoMatchError(err):
+			continue
+		case err != nil:
+			return resources[i:], fmt.Errorf("failed to get %s %s: %v", r.Kind, r.Name, err)
+		}
+		// the object has been taken over by someone else
+		labels := obj.GetLabels()
+		if labels[ExtensionNameLabel] != owner.Name || labels[ExtensionNamespaceLabel] != owner.Namespace {
+			continue
+		}
+		if err := a.client.Delete(ctx, obj, client.PropagationPolicy("Background")); err != nil && !apierrors.IsNotFound(err) {
+			return resources[i:], fmt.Errorf("failed to delete %s %s: %v", r.Kind, r.Name, err)
+		}
+	}
+	return nil, nil
+}
+
+func (a *Applier) apply(ctx context.Context, owner types.NamespacedName, obj *unstructured.Unstructured) error {
+	mapping, err := a.client.RESTMapper().RESTMapping(obj.GroupVersionKind().GroupKind(), obj.GroupVersionKind().Version)
+	if err != nil {
+		return err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if obj.GetNamespace() == "" {
+			obj.SetNamespace(owner.Namespace)
+		}
+	} else {
+		obj.SetNamespace("")
+	}
+
+	labels := obj.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[ExtensionNameLabel] = owner.Name
+	labels[ExtensionNamespaceLabel] = owner.Namespace
+	obj.SetLabels(labels)
+
+	return a.client.Patch(ctx, obj, client.Apply, client.FieldOwner(FieldOwner), client.ForceOwnership)
+}
+
+// keepPrevious appends the previous objects that have not been applied to the inventory
+func keepPrevious(inventory []extensionv1.ResourceStatus, previous []extensionv1.ResourceStatus, applied map[string]bool) []extensionv1.ResourceStatus {
+	for _, r := range previous {
+		if !applied[resourceKey(r)] {
+			inventory = append(inventory, r)
+		}
+	}
+	return inventory
+}
+
+func toResourceStatus(obj *unstructured.Unstructured) extensionv1.ResourceStatus {
+	gvk := obj.GroupVersionKind()
+	return extensionv1.ResourceStatus{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+func resourceKey(r extensionv1.ResourceStatus) string {
+	return fmt.Sprintf("%s/%s/%s/%s", r.Group, r.Kind, r.Namespace, r.Name)
+}
@@ -0,0 +1,141 @@
+package resources
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// applyClient adds a REST mapper and server-side apply, emulated by creating or replacing the object, to the fake
+// client
+type applyClient struct {
+	client.Client
+	mapper meta.RESTMapper
+}
+
+func (c *applyClient) RESTMapper() meta.RESTMapper {
+	return c.mapper
+}
+
+func (c *applyClient) Patch(ctx context.Context, obj client.Object, patch client.Patch, opts ...client.PatchOption) error {
+	if patch.Type() != types.ApplyPatchType {
+		return c.Client.Patch(ctx, obj, patch, opts...)
+	}
+	existing := obj.DeepCopyObject().(client.Object)
+	if err := c.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		return c.Create(ctx, obj)
+	} else if err != nil {
+		return err
+	}
+	obj.SetResourceVersion(existing.GetResourceVersion())
+	return c.Update(ctx, obj)
+}
+
+func newApplyClient() *applyClient {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	mapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	return &applyClient{Client: fake.NewClientBuilder().WithScheme(clientgoscheme.Scheme).Build(), mapper: mapper}
+}
+
+func newUnstructured(apiVersion, kind, namespace, name string) unstructured.Unstructured {
+	obj := unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	return obj
+}
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	c := newApplyClient()
+	applier := NewApplier(c)
+	owner := types.NamespacedName{Namespace: "argocd", Name: "metrics"}
+
+	inventory, err := applier.Apply(ctx, owner, []unstructured.Unstructured{
+		newUnstructured("v1", "ConfigMap", "", "metrics-dashboards"),
+		newUnstructured("v1", "Namespace", "argocd", "metrics"),
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []extensionv1.ResourceStatus{
+		{Version: "v1", Kind: "Namespace", Name: "metrics", Health: extensionv1.HealthStatusHealthy},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "argocd", Name: "metrics-dashboards", Health: extensionv1.HealthStatusHealthy},
+	}
+	if len(inventory) != len(want) || inventory[0] != want[0] || inventory[1] != want[1] {
+		t.Fatalf("Apply() = %+v, want %+v", inventory, want)
+	}
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "argocd", Name: "metrics-dashboards"}, &cm); err != nil {
+		t.Fatal(err)
+	}
+	if cm.Labels[ExtensionNameLabel] != "metrics" || cm.Labels[ExtensionNamespaceLabel] != "argocd" {
+		t.Errorf("labels = %v, want the owner extension labels", cm.Labels)
+	}
+
+	// objects that are no longer desired are pruned
+	inventory, err = applier.Apply(ctx, owner, []unstructured.Unstructured{newUnstructured("v1", "Namespace", "", "metrics")}, inventory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inventory) != 1 || inventory[0].Kind != "Namespace" {
+		t.Errorf("Apply() = %+v, want the namespace only", inventory)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "argocd", Name: "metrics-dashboards"}, &cm); !apierrors.IsNotFound(err) {
+		t.Errorf("pruned config map still exists: %v", err)
+	}
+
+	// failing objects keep the previous inventory, so the objects can still be pruned later
+	inventory, err = applier.Apply(ctx, owner, []unstructured.Unstructured{newUnstructured("example.com/v1", "Widget", "", "metrics")}, inventory)
+	if err == nil {
+		t.Fatal("Apply() of an unknown kind succeeded")
+	}
+	if len(inventory) != 1 || inventory[0].Kind != "Namespace" {
+		t.Errorf("Apply() = %+v, want the previous namespace", inventory)
+	}
+}
+
+func TestPrune(t *testing.T) {
+	ctx := context.Background()
+	c := newApplyClient()
+	applier := NewApplier(c)
+	owner := types.NamespacedName{Namespace: "argocd", Name: "metrics"}
+	labeled := func(name string, extension string) *corev1.ConfigMap {
+		return &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Namespace: "argocd", Name: name, Labels: map[string]string{
+			ExtensionNameLabel: extension, ExtensionNamespaceLabel: "argocd",
+		}}}
+	}
+	for _, cm := range []*corev1.ConfigMap{labeled("owned", "metrics"), labeled("taken-over", "logs")} {
+		if err := c.Create(ctx, cm); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resource := func(name string) extensionv1.ResourceStatus {
+		return extensionv1.ResourceStatus{Version: "v1", Kind: "ConfigMap", Namespace: "argocd", Name: name}
+	}
+	remaining, err := applier.Prune(ctx, owner, []extensionv1.ResourceStatus{resource("owned"), resource("taken-over"), resource("missing")})
+	if err != nil || len(remaining) > 0 {
+		t.Fatalf("Prune() = %v, %v", remaining, err)
+	}
+	var cm corev1.ConfigMap
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "argocd", Name: "owned"}, &cm); !apierrors.IsNotFound(err) {
+		t.Errorf("owned config map still exists: %v", err)
+	}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "argocd", Name: "taken-over"}, &cm); err != nil {
+		t.Errorf("config map taken over by another extension is deleted: %v", err)
+	}
+}
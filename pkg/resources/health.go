@@ -0,0 +1,177 @@
+package resources
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+// Health assesses the health of well-known kinds from the object status. Objects of other kinds are considered
+// healthy once they have been applied.
+func Health(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	gk := obj.GroupVersionKind().GroupKind()
+	switch {
+	case gk.Group == "apps" && gk.Kind == "Deployment":
+		return deploymentHealth(obj)
+	case gk.Group == "apps" && gk.Kind == "StatefulSet":
+		return statefulSetHealth(obj)
+	case gk.Group == "apps" && gk.Kind == "DaemonSet":
+		return daemonSetHealth(obj)
+	case gk.Group == "batch" && gk.Kind == "Job":
+		return jobHealth(obj)
+	case gk.Group == "" && gk.Kind == "Service":
+		return serviceHealth(obj)
+	case gk.Group == "" && gk.Kind == "PersistentVolumeClaim":
+		return pvcHealth(obj)
+	case gk.Group == "" && gk.Kind == "Pod":
+		return podHealth(obj)
+	case gk.Group == "apiextensions.k8s.io" && gk.Kind == "CustomResourceDefinition":
+		return crdHealth(obj)
+	}
+	return extensionv1.HealthStatusHealthy, ""
+}
+
+func deploymentHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	if !generationObserved(obj) {
+		return extensionv1.HealthStatusProgressing, "Waiting for rollout to be observed"
+	}
+	if c := findCondition(obj, "Progressing"); c != nil && c["reason"] == "ProgressDeadlineExceeded" {
+		return extensionv1.HealthStatusDegraded, fmt.Sprintf("Deployment %s has exceeded its progress deadline", obj.GetName())
+	}
+	replicas := desiredReplicas(obj)
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedReplicas")
+	total, _, _ := unstructured.NestedInt64(obj.Object, "status", "replicas")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "availableReplicas")
+	switch {
+	case updated < replicas:
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d of %d replicas have been updated", updated, replicas)
+	case total > updated:
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d old replicas are pending termination", total-updated)
+	case available < updated:
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d of %d updated replicas are available", available, updated)
+	}
+	return extensionv1.HealthStatusHealthy, ""
+}
+
+func statefulSetHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	if !generationObserved(obj) {
+		return extensionv1.HealthStatusProgressing, "Waiting for rollout to be observed"
+	}
+	replicas := desiredReplicas(obj)
+	ready, _, _ := unstructured.NestedInt64(obj.Object, "status", "readyReplicas")
+	if ready < replicas {
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d of %d replicas are ready", ready, replicas)
+	}
+	current, _, _ := unstructured.NestedString(obj.Object, "status", "currentRevision")
+	update, _, _ := unstructured.NestedString(obj.Object, "status", "updateRevision")
+	strategy, _, _ := unstructured.NestedString(obj.Object, "spec", "updateStrategy", "type")
+	if strategy != "OnDelete" && update != "" && current != update {
+		return extensionv1.HealthStatusProgressing, "Waiting for rolling update to complete"
+	}
+	return extensionv1.HealthStatusHealthy, ""
+}
+
+func daemonSetHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	if !generationObserved(obj) {
+		return extensionv1.HealthStatusProgressing, "Waiting for rollout to be observed"
+	}
+	desired, _, _ := unstructured.NestedInt64(obj.Object, "status", "desiredNumberScheduled")
+	updated, _, _ := unstructured.NestedInt64(obj.Object, "status", "updatedNumberScheduled")
+	available, _, _ := unstructured.NestedInt64(obj.Object, "status", "numberAvailable")
+	switch {
+	case updated < desired:
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d of %d pods have been updated", updated, desired)
+	case available < desired:
+		return extensionv1.HealthStatusProgressing, fmt.Sprintf("%d of %d pods are available", available, desired)
+	}
+	return extensionv1.HealthStatusHealthy, ""
+}
+
+func jobHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	if c := findCondition(obj, "Failed"); c != nil && c["status"] == "True" {
+		message, _ := c["message"].(string)
+		return extensionv1.HealthStatusDegraded, message
+	}
+	if c := findCondition(obj, "Complete"); c != nil && c["status"] == "True" {
+		return extensionv1.HealthStatusHealthy, ""
+	}
+	return extensionv1.HealthStatusProgressing, "Job has not completed yet"
+}
+
+func serviceHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	serviceType, _, _ := unstructured.NestedString(obj.Object, "spec", "type")
+	if serviceType != "LoadBalancer" {
+		return extensionv1.HealthStatusHealthy, ""
+	}
+	ingress, _, _ := unstructured.NestedSlice(obj.Object, "status", "loadBalancer", "ingress")
+	if len(ingress) == 0 {
+		return extensionv1.HealthStatusProgressing, "Waiting for load balancer to be provisioned"
+	}
+	return extensionv1.HealthStatusHealthy, ""
+}
+
+func pvcHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	switch phase {
+	case "Bound":
+		return extensionv1.HealthStatusHealthy, ""
+	case "Lost":
+		return extensionv1.HealthStatusDegraded, "Volume claim has lost its volume"
+	}
+	return extensionv1.HealthStatusProgressing, "Waiting for volume claim to be bound"
+}
+
+func podHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	phase, _, _ := unstructured.NestedString(obj.Object, "status", "phase")
+	message, _, _ := unstructured.NestedString(obj.Object, "status", "message")
+	switch phase {
+	case "Succeeded":
+		return extensionv1.HealthStatusHealthy, message
+	case "Failed":
+		return extensionv1.HealthStatusDegraded, message
+	case "Running":
+		if c := findCondition(obj, "Ready"); c != nil && c["status"] == "True" {
+			return extensionv1.HealthStatusHealthy, ""
+		}
+		return extensionv1.HealthStatusProgressing, "Waiting for pod to be ready"
+	}
+	return extensionv1.HealthStatusProgressing, message
+}
+
+func crdHealth(obj *unstructured.Unstructured) (extensionv1.HealthStatus, string) {
+	if c := findCondition(obj, "NamesAccepted"); c != nil && c["status"] == "False" {
+		message, _ := c["message"].(string)
+		return extensionv1.HealthStatusDegraded, message
+	}
+	if c := findCondition(obj, "Established"); c != nil && c["status"] == "True" {
+		return extensionv1.HealthStatusHealthy, ""
+	}
+	return extensionv1.HealthStatusProgressing, "Waiting for custom resource definition to be established"
+}
+
+// generationObserved returns true if the controller has observed the latest spec of the object
+func generationObserved(obj *unstructured.Unstructured) bool {
+	observed, _, _ := unstructured.NestedInt64(obj.Object, "status", "observedGeneration")
+	return observed >= obj.GetGeneration()
+}
+
+// desiredReplicas returns spec.replicas, which defaults to 1
+func desiredReplicas(obj *unstructured.Unstructured) int64 {
+	replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas")
+	if !found {
+		return 1
+	}
+	return replicas
+}
+
+func findCondition(obj *unstructured.Unstructured, conditionType string) map[string]interface{} {
+	conditions, _, _ := unstructured.NestedSlice(obj.Object, "status", "conditions")
+	for _, item := range conditions {
+		if c, ok := item.(map[string]interface{}); ok && c["type"] == conditionType {
+			return c
+		}
+	}
+	return nil
+}
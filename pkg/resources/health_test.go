@@ -0,0 +1,95 @@
+package resources
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/yaml"
+
+	extensionv1 "github.com/argoproj/argocd-extensions/api/v1beta1"
+)
+
+func TestHealth(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		obj  string
+		want extensionv1.HealthStatus
+	}{
+		{name: "deployment rolled out", want: extensionv1.HealthStatusHealthy, obj: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {generation: 2}
+spec: {replicas: 2}
+status: {observedGeneration: 2, replicas: 2, updatedReplicas: 2, availableReplicas: 2}`},
+		{name: "deployment not observed", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: apps/v1
+kind: Deployment
+metadata: {generation: 2}
+status: {observedGeneration: 1, replicas: 1, updatedReplicas: 1, availableReplicas: 1}`},
+		{name: "deployment updating", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: apps/v1
+kind: Deployment
+spec: {replicas: 3}
+status: {replicas: 3, updatedReplicas: 1, availableReplicas: 3}`},
+		{name: "deployment deadline exceeded", want: extensionv1.HealthStatusDegraded, obj: `
+apiVersion: apps/v1
+kind: Deployment
+status:
+  conditions: [{type: Progressing, status: "False", reason: ProgressDeadlineExceeded}]`},
+		{name: "statefulset rolling update", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: apps/v1
+kind: StatefulSet
+status: {readyReplicas: 1, currentRevision: a, updateRevision: b}`},
+		{name: "daemonset available", want: extensionv1.HealthStatusHealthy, obj: `
+apiVersion: apps/v1
+kind: DaemonSet
+status: {desiredNumberScheduled: 2, updatedNumberScheduled: 2, numberAvailable: 2}`},
+		{name: "job failed", want: extensionv1.HealthStatusDegraded, obj: `
+apiVersion: batch/v1
+kind: Job
+status:
+  conditions: [{type: Failed, status: "True", message: BackoffLimitExceeded}]`},
+		{name: "job running", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: batch/v1
+kind: Job`},
+		{name: "load balancer pending", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: v1
+kind: Service
+spec: {type: LoadBalancer}`},
+		{name: "cluster ip service", want: extensionv1.HealthStatusHealthy, obj: `
+apiVersion: v1
+kind: Service
+spec: {type: ClusterIP}`},
+		{name: "volume claim lost", want: extensionv1.HealthStatusDegraded, obj: `
+apiVersion: v1
+kind: PersistentVolumeClaim
+status: {phase: Lost}`},
+		{name: "pod not ready", want: extensionv1.HealthStatusProgressing, obj: `
+apiVersion: v1
+kind: Pod
+status: {phase: Running}`},
+		{name: "crd established", want: extensionv1.HealthStatusHealthy, obj: `
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+status:
+  conditions: [{type: Established, status: "True"}]`},
+		{name: "other kind", want: extensionv1.HealthStatusHealthy, obj: `
+apiVersion: v1
+kind: ConfigMap`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			// numbers are decoded as int64 like objects returned by the API server
+			data, err := yaml.YAMLToJSON([]byte(tc.obj))
+			if err != nil {
+				t.Fatal(err)
+			}
+			obj := &unstructured.Unstructured{}
+			if err := obj.UnmarshalJSON(data); err != nil {
+				t.Fatal(err)
+			}
+			if got, message := Health(obj); got != tc.want {
+				t.Errorf("Health() = %s (%s), want %s", got, message, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package settings
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ArgoCDRBACConfigMapName is the name of the Argo CD RBAC ConfigMap
+	ArgoCDRBACConfigMapName = "argocd-rbac-cm"
+	// PolicyCSVKey is the argocd-rbac-cm key that holds the RBAC policy
+	PolicyCSVKey = "policy.csv"
+
+	blockBeginFormat = "# BEGIN argocd-extensions: %s"
+	blockEndFormat   = "# END argocd-extensions: %s"
+)
+
+// SetOwnedBlock replaces the delimited block of lines owned by the owner in the ConfigMap key. The block is
+// appended if it does not exist yet and removed if no lines are desired. Lines outside of the block are preserved.
+func SetOwnedBlock(cm *corev1.ConfigMap, owner string, key string, lines []string) error {
+	begin, end := fmt.Sprintf(blockBeginFormat, owner), fmt.Sprintf(blockEndFormat, owner)
+
+	var kept []string
+	inBlock, found := false, false
+	for _, line := range splitLines(cm.Data[key]) {
+		switch {
+		case strings.TrimSpace(line) == begin:
+			inBlock, found = true, true
+		case strings.TrimSpace(line) == end && inBlock:
+			inBlock = false
+		case !inBlock:
+			kept = append(kept, line)
+		}
+	}
+	if inBlock {
+		return fmt.Errorf("%s in %s has no matching %q line", begin, key, end)
+	}
+	if len(lines) > 0 {
+		kept = append(kept, begin)
+		kept = append(kept, lines...)
+		kept = append(kept, end)
+	}
+
+	value := strings.Join(kept, "\n")
+	if value != "" {
+		value += "\n"
+	}
+	if (found || len(lines) > 0) && value != cm.Data[key] {
+		if value == "" {
+			delete(cm.Data, key)
+		} else {
+			if cm.Data == nil {
+				cm.Data = map[string]string{}
+			}
+			cm.Data[key] = value
+		}
+	}
+
+	owners, err := getOwnership(cm)
+	if err != nil {
+		return err
+	}
+	own := owners[owner]
+	var blocks []string
+	for _, k := range own.Blocks {
+		if k != key {
+			blocks = append(blocks, k)
+		}
+	}
+	if len(lines) > 0 {
+		blocks = append(blocks, key)
+	}
+	sort.Strings(blocks)
+	own.Blocks = blocks
+	owners[owner] = own
+	return setOwnership(cm, owners)
+}
+
+// HasPermission returns true if any policy in the RBAC ConfigMap explicitly allows the action on the object
+// of the given resource. Subjects and role inheritance are not evaluated.
+func HasPermission(cm *corev1.ConfigMap, resource string, action string, object string) bool {
+	for key, value := range cm.Data {
+		if key != PolicyCSVKey && !(strings.HasPrefix(key, "policy.") && strings.HasSuffix(key, ".csv")) {
+			continue
+		}
+		for _, line := range splitLines(value) {
+			fields, err := parsePolicyLine(line)
+			if err != nil || len(fields) < 6 || fields[0] != "p" {
+				continue
+			}
+			if fields[2] == resource && matches(fields[3], action) && matches(fields[4], object) && fields[5] == "allow" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasPolicyLine returns true if the line is present in any policy of the RBAC ConfigMap
+func HasPolicyLine(cm *corev1.ConfigMap, line string) bool {
+	expected, err := parsePolicyLine(line)
+	if err != nil {
+		return false
+	}
+	for key, value := range cm.Data {
+		if key != PolicyCSVKey && !(strings.HasPrefix(key, "policy.") && strings.HasSuffix(key, ".csv")) {
+			continue
+		}
+		for _, l := range splitLines(value) {
+			if fields, err := parsePolicyLine(l); err == nil && strings.Join(fields, ",") == strings.Join(expected, ",") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parsePolicyLine splits the casbin policy line into trimmed fields. Returns no fields for empty and comment lines.
+func parsePolicyLine(line string) ([]string, error) {
+	if strings.ContainsAny(line, "\r\n") {
+		return nil, fmt.Errorf("policy line %q must not contain line breaks", line)
+	}
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	r := csv.NewReader(strings.NewReader(line))
+	r.TrimLeadingSpace = true
+	fields, err := r.Read()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.Read(); err != io.EOF {
+		return nil, fmt.Errorf("policy line %q must hold a single record", line)
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+// ValidatePolicyLine returns an error unless the line is a single policy record of the given type, e.g. p or g, with
+// the given number of non-empty fields
+func ValidatePolicyLine(line string, kind string, n int) error {
+	fields, err := parsePolicyLine(line)
+	if err != nil {
+		return err
+	}
+	if len(fields) != n || fields[0] != kind {
+		return fmt.Errorf("line %q must be a %s line with %d fields", line, kind, n)
+	}
+	for _, f := range fields {
+		if f == "" {
+			return fmt.Errorf("line %q must not have empty fields", line)
+		}
+	}
+	return nil
+}
+
+// ValidateExtensionPolicy returns an error unless the policies only allow invoking the named extension and the bindings
+// only assign roles defined by the policies. The roles must be named role:<name> or role:<name>-<suffix>, so that the
+// bindings can't grant the permissions of roles managed outside of the extension.
+func ValidateExtensionPolicy(name string, policies []string, bindings []string) error {
+	roles := map[string]bool{}
+	for _, line := range policies {
+		if err := ValidatePolicyLine(line, "p", 6); err != nil {
+			return err
+		}
+		fields, _ := parsePolicyLine(line)
+		role := fields[1]
+		if role != "role:"+name && !strings.HasPrefix(role, "role:"+name+"-") {
+			return fmt.Errorf("policy %q must assign a role named role:%s or role:%s-<suffix>", line, name, name)
+		}
+		if fields[2] != "extensions" || fields[3] != "invoke" || fields[4] != name || fields[5] != "allow" {
+			return fmt.Errorf("policy %q must have the format 'p, <role>, extensions, invoke, %s, allow'", line, name)
+		}
+		roles[role] = true
+	}
+	for _, line := range bindings {
+		if err := ValidatePolicyLine(line, "g", 3); err != nil {
+			return err
+		}
+		fields, _ := parsePolicyLine(line)
+		if !roles[fields[2]] {
+			return fmt.Errorf("binding %q must assign a role defined by the extension policies", line)
+		}
+	}
+	return nil
+}
+
+// BindingLine returns the policy line assigning the role to the subject. Returns an error if the subject or the role
+// is empty or would add fields or lines to the policy.
+func BindingLine(subject string, role string) (string, error) {
+	for _, value := range []string{subject, role} {
+		if strings.TrimSpace(value) == "" || strings.ContainsAny(value, ",\"\r\n") {
+			return "", fmt.Errorf("role binding subject and role must not be empty or contain commas, quotes or line breaks: %q, %q", subject, role)
+		}
+	}
+	line := fmt.Sprintf("g, %s, %s", subject, role)
+	return line, ValidatePolicyLine(line, "g", 3)
+}
+
+func matches(pattern string, value string) bool {
+	ok, err := path.Match(pattern, value)
+	return err == nil && ok
+}
+
+func splitLines(value string) []string {
+	value = strings.TrimSuffix(value, "\n")
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, "\n")
+}
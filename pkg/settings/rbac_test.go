@@ -0,0 +1,97 @@
+package settings
+
+import (
+	"testing"
+)
+
+func TestValidatePolicyLine(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		line    string
+		kind    string
+		n       int
+		wantErr bool
+	}{
+		{name: "policy", line: "p, role:metrics, extensions, invoke, metrics, allow", kind: "p", n: 6},
+		{name: "binding", line: "g, alice, role:metrics", kind: "g", n: 3},
+		{name: "quoted field", line: `g, "alice", role:metrics`, kind: "g", n: 3},
+		{name: "wrong type", line: "p, alice, role:metrics", kind: "g", n: 3, wantErr: true},
+		{name: "too many fields", line: "g, alice, role:metrics, extra", kind: "g", n: 3, wantErr: true},
+		{name: "empty field", line: "g, , role:metrics", kind: "g", n: 3, wantErr: true},
+		{name: "comment", line: "# g, alice, role:metrics", kind: "g", n: 3, wantErr: true},
+		{name: "embedded newline", line: "g, alice, role:metrics\np, alice, *, *, *, allow", kind: "g", n: 3, wantErr: true},
+		{name: "embedded carriage return", line: "g, alice, role:metrics\rg, alice, role:admin", kind: "g", n: 3, wantErr: true},
+		{name: "quoted newline", line: "g, \"alice\nbob\", role:metrics", kind: "g", n: 3, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidatePolicyLine(tc.line, tc.kind, tc.n)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidatePolicyLine(%q) error = %v, wantErr %v", tc.line, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateExtensionPolicy(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		policies []string
+		bindings []string
+		wantErr  bool
+	}{
+		{name: "empty"},
+		{name: "valid", policies: []string{"p, role:metrics, extensions, invoke, metrics, allow"},
+			bindings: []string{"g, role:readonly, role:metrics", "g, my-org:sre, role:metrics"}},
+		{name: "suffixed role", policies: []string{"p, role:metrics-viewer, extensions, invoke, metrics, allow"},
+			bindings: []string{"g, role:readonly, role:metrics-viewer"}},
+		{name: "wildcard object", policies: []string{"p, role:metrics, extensions, invoke, *, allow"}, wantErr: true},
+		{name: "other extension", policies: []string{"p, role:metrics, extensions, invoke, logs, allow"}, wantErr: true},
+		{name: "other resource", policies: []string{"p, role:metrics, applications, get, */*, allow"}, wantErr: true},
+		{name: "other action", policies: []string{"p, role:metrics, extensions, *, metrics, allow"}, wantErr: true},
+		{name: "deny", policies: []string{"p, role:metrics, extensions, invoke, metrics, deny"}, wantErr: true},
+		{name: "built-in role", policies: []string{"p, role:readonly, extensions, invoke, metrics, allow"}, wantErr: true},
+		{name: "role prefix", policies: []string{"p, role:metricsadmin, extensions, invoke, metrics, allow"}, wantErr: true},
+		{name: "user subject", policies: []string{"p, alice, extensions, invoke, metrics, allow"}, wantErr: true},
+		{name: "binding to admin", policies: []string{"p, role:metrics, extensions, invoke, metrics, allow"},
+			bindings: []string{"g, my-org:sre, role:admin"}, wantErr: true},
+		{name: "binding without policies", bindings: []string{"g, my-org:sre, role:metrics"}, wantErr: true},
+		{name: "policy as binding", bindings: []string{"p, role:readonly, *, *, *, allow"}, wantErr: true},
+		{name: "binding as policy", policies: []string{"g, role:metrics, role:admin"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateExtensionPolicy("metrics", tc.policies, tc.bindings)
+			if (err != nil) != tc.wantErr {
+				t.Errorf("ValidateExtensionPolicy() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestBindingLine(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		subject string
+		role    string
+		want    string
+		wantErr bool
+	}{
+		{name: "valid", subject: "alice", role: "role:metrics", want: "g, alice, role:metrics"},
+		{name: "group with spaces", subject: "my team", role: "role:metrics", want: "g, my team, role:metrics"},
+		{name: "empty subject", subject: "", role: "role:metrics", wantErr: true},
+		{name: "blank role", subject: "alice", role: " ", wantErr: true},
+		{name: "comma in subject", subject: "alice, role:admin", role: "role:metrics", wantErr: true},
+		{name: "newline in role", subject: "alice", role: "role:metrics\np, alice, *, *, *, allow", wantErr: true},
+		{name: "carriage return in subject", subject: "alice\rg", role: "role:metrics", wantErr: true},
+		{name: "quote in subject", subject: `alice"`, role: "role:metrics", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := BindingLine(tc.subject, tc.role)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("BindingLine(%q, %q) error = %v, wantErr %v", tc.subject, tc.role, err, tc.wantErr)
+			}
+			if got != tc.want && !tc.wantErr {
+				t.Errorf("BindingLine(%q, %q) = %q, want %q", tc.subject, tc.role, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,368 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// ArgoCDConfigMapName is the name of the Argo CD settings ConfigMap
+	ArgoCDConfigMapName = "argocd-cm"
+
+	// ExtensionConfigKey is the argocd-cm key that holds proxy extensions configuration
+	ExtensionConfigKey = "extension.config"
+
+	// managedAnnotation records which ConfigMap settings are owned by which extension
+	managedAnnotation = "argocd-extensions.argoproj.io/managed"
+)
+
+// ownership holds the ConfigMap settings owned by a single extension
+type ownership struct {
+	// Keys lists ConfigMap keys fully owned by the extension
+	Keys []string `json:"keys,omitempty"`
+	// Entries maps ConfigMap keys holding a list to the identifiers of the list entries owned by the extension
+	Entries map[string][]string `json:"entries,omitempty"`
+	// Blocks lists ConfigMap keys holding a delimited block of lines owned by the extension
+	Blocks []string `json:"blocks,omitempty"`
+}
+
+// ListSetting describes a ConfigMap key that holds a YAML list of entries shared by multiple owners
+type ListSetting struct {
+	// Key is the ConfigMap key
+	Key string
+	// Field is the field of the YAML object holding the list, or empty if the value is the list itself
+	Field string
+	// ID is the entry field that uniquely identifies the entry
+	ID string
+}
+
+var (
+	// ExtensionConfig is the list of proxy extensions in argocd-cm
+	ExtensionConfig = ListSetting{Key: ExtensionConfigKey, Field: "extensions", ID: "name"}
+	// ApplicationLinks is the list of application deep links in argocd-cm
+	ApplicationLinks = ListSetting{Key: "application.links", ID: "title"}
+	// ResourceLinks is the list of resource deep links in argocd-cm
+	ResourceLinks = ListSetting{Key: "resource.links", ID: "title"}
+	// ProjectLinks is the list of project deep links in argocd-cm
+	ProjectLinks = ListSetting{Key: "project.links", ID: "title"}
+
+	listSettings = map[string]ListSetting{
+		ExtensionConfig.Key:  ExtensionConfig,
+		ApplicationLinks.Key: ApplicationLinks,
+		ResourceLinks.Key:    ResourceLinks,
+		ProjectLinks.Key:     ProjectLinks,
+	}
+)
+
+// Manager updates Argo CD settings ConfigMaps on behalf of extensions
+type Manager struct {
+	client    client.Client
+	namespace string
+}
+
+// NewManager creates a Manager that updates ConfigMaps in the given namespace
+func NewManager(c client.Client, namespace string) *Manager {
+	return &Manager{client: c, namespace: namespace}
+}
+
+// Update applies the mutation to the named ConfigMap and persists it if anything has changed. The mutation is
+// retried on conflicts. A missing ConfigMap is only an error if the mutation attempts to change it.
+func (m *Manager) Update(ctx context.Context, name string, mutate func(cm *corev1.ConfigMap) error) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		var cm corev1.ConfigMap
+		notFound := false
+		if err := m.client.Get(ctx, types.NamespacedName{Namespace: m.namespace, Name: name}, &cm); err != nil {
+			if !apierrors.IsNotFound(err) {
+				return err
+			}
+			notFound = true
+		}
+		original := cm.DeepCopy()
+		if err := mutate(&cm); err != nil {
+			return err
+		}
+		if reflect.DeepEqual(original.Data, cm.Data) && reflect.DeepEqual(original.Annotations, cm.Annotations) {
+			return nil
+		}
+		if notFound {
+			return fmt.Errorf("ConfigMap %s/%s not found", m.namespace, name)
+		}
+		return m.client.Update(ctx, &cm)
+	})
+}
+
+// SetOwnedKeys sets the desired keys in the ConfigMap on behalf of the owner and removes keys previously owned by
+// the owner that are no longer desired. Keys that are set but not owned by the owner are never overwritten.
+func SetOwnedKeys(cm *corev1.ConfigMap, owner string, desired map[string]string) error {
+	owners, err := getOwnership(cm)
+	if err != nil {
+		return err
+	}
+	keyOwners := map[string]string{}
+	for o, own := range owners {
+		for _, key := range own.Keys {
+			keyOwners[key] = o
+		}
+	}
+
+	var keys []string
+	for key := range desired {
+		if keyOwner, ok := keyOwners[key]; ok && keyOwner != owner {
+			return fmt.Errorf("key %s is already managed by extension %s", key, keyOwner)
+		}
+		if _, ok := cm.Data[key]; ok && keyOwners[key] != owner {
+			return fmt.Errorf("key %s is already set in %s and is not managed by extensions", key, cm.Name)
+		}
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range owners[owner].Keys {
+		if _, ok := desired[key]; !ok {
+			delete(cm.Data, key)
+		}
+	}
+	if len(desired) > 0 && cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	for key, value := range desired {
+		cm.Data[key] = value
+	}
+
+	own := owners[owner]
+	own.Keys = keys
+	owners[owner] = own
+	return setOwnership(cm, owners)
+}
+
+// SetOwnedEntries sets the desired entries of the list setting on behalf of the owner and removes entries
+// previously owned by the owner that are no longer desired. Entries that are not owned by the owner are preserved.
+func SetOwnedEntries(cm *corev1.ConfigMap, owner string, setting ListSetting, desired []interface{}) error {
+	owners, err := getOwnership(cm)
+	if err != nil {
+		return err
+	}
+	entryOwners := map[string]string{}
+	for o, own := range owners {
+		for _, id := range own.Entries[setting.Key] {
+			entryOwners[id] = o
+		}
+	}
+
+	root, entries, err := parseList(cm.Data[setting.Key], setting)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %v", setting.Key, err)
+	}
+
+	desiredByID := map[string]map[string]interface{}{}
+	var ids []string
+	for _, item := range desired {
+		entry, err := toMap(item)
+		if err != nil {
+			return err
+		}
+		id, _ := entry[setting.ID].(string)
+		if id == "" {
+			return fmt.Errorf("%s entry is missing %s", setting.Key, setting.ID)
+		}
+		if _, ok := desiredByID[id]; ok {
+			return fmt.Errorf("%s entry %s is declared more than once", setting.Key, id)
+		}
+		if entryOwner, ok := entryOwners[id]; ok && entryOwner != owner {
+			return fmt.Errorf("%s entry %s is already managed by extension %s", setting.Key, id, entryOwner)
+		}
+		desiredByID[id] = entry
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	previous := map[string]bool{}
+	for _, id := range owners[owner].Entries[setting.Key] {
+		previous[id] = true
+	}
+	var updated []interface{}
+	for _, item := range entries {
+		entry, _ := item.(map[string]interface{})
+		id, _ := entry[setting.ID].(string)
+		if desiredEntry, ok := desiredByID[id]; ok {
+			if !previous[id] && entryOwners[id] != owner {
+				return fmt.Errorf("%s entry %s is already set in %s and is not managed by extensions", setting.Key, id, cm.Name)
+			}
+			updated = append(updated, desiredEntry)
+			delete(desiredByID, id)
+			continue
+		}
+		if previous[id] {
+			continue
+		}
+		updated = append(updated, item)
+	}
+	for _, id := range ids {
+		if entry, ok := desiredByID[id]; ok {
+			updated = append(updated, entry)
+		}
+	}
+
+	if err := setList(cm, setting, root, updated); err != nil {
+		return err
+	}
+
+	own := owners[owner]
+	if own.Entries == nil {
+		own.Entries = map[string][]string{}
+	}
+	own.Entries[setting.Key] = ids
+	if len(ids) == 0 {
+		delete(own.Entries, setting.Key)
+	}
+	if len(own.Entries) == 0 {
+		own.Entries = nil
+	}
+	owners[owner] = own
+	return setOwnership(cm, owners)
+}
+
+// RemoveOwner deletes all settings owned by the owner from the ConfigMap
+func RemoveOwner(cm *corev1.ConfigMap, owner string) error {
+	owners, err := getOwnership(cm)
+	if err != nil {
+		return err
+	}
+	for _, key := range owners[owner].Blocks {
+		if err := SetOwnedBlock(cm, owner, key, nil); err != nil {
+			return err
+		}
+	}
+	for key := range owners[owner].Entries {
+		setting, ok := listSettings[key]
+		if !ok {
+			return fmt.Errorf("unknown list setting %s", key)
+		}
+		if err := SetOwnedEntries(cm, owner, setting, nil); err != nil {
+			return err
+		}
+	}
+	return SetOwnedKeys(cm, owner, nil)
+}
+
+// parseList returns the parsed YAML root object and the list of entries stored in the setting value
+func parseList(value string, setting ListSetting) (map[string]interface{}, []interface{}, error) {
+	if setting.Field == "" {
+		var entries []interface{}
+		if err := yaml.Unmarshal([]byte(value), &entries); err != nil {
+			return nil, nil, err
+		}
+		return nil, entries, nil
+	}
+	root := map[string]interface{}{}
+	if err := yaml.Unmarshal([]byte(value), &root); err != nil {
+		return nil, nil, err
+	}
+	if root == nil {
+		root = map[string]interface{}{}
+	}
+	entries, _ := root[setting.Field].([]interface{})
+	return root, entries, nil
+}
+
+// setList stores the list of entries in the setting value, removing the key if nothing is left
+func setList(cm *corev1.ConfigMap, setting ListSetting, root map[string]interface{}, entries []interface{}) error {
+	var value interface{} = entries
+	empty := len(entries) == 0
+	if setting.Field != "" {
+		if len(entries) == 0 {
+			delete(root, setting.Field)
+		} else {
+			root[setting.Field] = entries
+		}
+		value = root
+		empty = len(root) == 0
+	}
+	if empty {
+		delete(cm.Data, setting.Key)
+		return nil
+	}
+
+	// keep the current value formatting if the content has not changed
+	var current interface{}
+	if err := yaml.Unmarshal([]byte(cm.Data[setting.Key]), &current); err == nil {
+		if equal, err := jsonEqual(current, value); err == nil && equal {
+			return nil
+		}
+	}
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if cm.Data == nil {
+		cm.Data = map[string]string{}
+	}
+	cm.Data[setting.Key] = string(data)
+	return nil
+}
+
+// jsonEqual returns true if both values have the same JSON representation
+func jsonEqual(a interface{}, b interface{}) (bool, error) {
+	aData, err := json.Marshal(a)
+	if err != nil {
+		return false, err
+	}
+	bData, err := json.Marshal(b)
+	if err != nil {
+		return false, err
+	}
+	return string(aData) == string(bData), nil
+}
+
+func toMap(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	res := map[string]interface{}{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+func getOwnership(cm *corev1.ConfigMap) (map[string]ownership, error) {
+	owners := map[string]ownership{}
+	if data, ok := cm.Annotations[managedAnnotation]; ok {
+		if err := json.Unmarshal([]byte(data), &owners); err != nil {
+			return nil, fmt.Errorf("failed to parse %s annotation of %s: %v", managedAnnotation, cm.Name, err)
+		}
+	}
+	return owners, nil
+}
+
+func setOwnership(cm *corev1.ConfigMap, owners map[string]ownership) error {
+	for o, own := range owners {
+		if reflect.DeepEqual(own, ownership{}) {
+			delete(owners, o)
+		}
+	}
+	if len(owners) == 0 {
+		delete(cm.Annotations, managedAnnotation)
+		return nil
+	}
+	data, err := json.Marshal(owners)
+	if err != nil {
+		return err
+	}
+	if cm.Annotations == nil {
+		cm.Annotations = map[string]string{}
+	}
+	cm.Annotations[managedAnnotation] = string(data)
+	return nil
+}
@@ -0,0 +1,127 @@
+package settings
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newConfigMap(data map[string]string, managed string) *corev1.ConfigMap {
+	cm := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: ArgoCDConfigMapName}, Data: data}
+	if managed != "" {
+		cm.Annotations = map[string]string{managedAnnotation: managed}
+	}
+	return cm
+}
+
+func TestSetOwnedKeys(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		cm          *corev1.ConfigMap
+		owner       string
+		desired     map[string]string
+		wantData    map[string]string
+		wantManaged string
+		wantErr     bool
+	}{
+		{
+			name:        "add keys",
+			cm:          newConfigMap(map[string]string{"url": "https://argocd"}, ""),
+			owner:       "metrics",
+			desired:     map[string]string{"resource.customizations.health.example.com_Widget": "hs = {}"},
+			wantData:    map[string]string{"url": "https://argocd", "resource.customizations.health.example.com_Widget": "hs = {}"},
+			wantManaged: `{"metrics":{"keys":["resource.customizations.health.example.com_Widget"]}}`,
+		},
+		{
+			name: "update and remove keys",
+			cm: newConfigMap(map[string]string{"url": "https://argocd", "a": "1", "b": "2"},
+				`{"metrics":{"keys":["a","b"]}}`),
+			owner:       "metrics",
+			desired:     map[string]string{"a": "10", "c": "3"},
+			wantData:    map[string]string{"url": "https://argocd", "a": "10", "c": "3"},
+			wantManaged: `{"metrics":{"keys":["a","c"]}}`,
+		},
+		{
+			name:        "remove all keys",
+			cm:          newConfigMap(map[string]string{"url": "https://argocd", "a": "1"}, `{"metrics":{"keys":["a"]}}`),
+			owner:       "metrics",
+			wantData:    map[string]string{"url": "https://argocd"},
+			wantManaged: "",
+		},
+		{
+			name:        "keep keys of other owners",
+			cm:          newConfigMap(map[string]string{"a": "1", "b": "2"}, `{"logs":{"keys":["b"]},"metrics":{"keys":["a"]}}`),
+			owner:       "metrics",
+			desired:     map[string]string{"c": "3"},
+			wantData:    map[string]string{"b": "2", "c": "3"},
+			wantManaged: `{"logs":{"keys":["b"]},"metrics":{"keys":["c"]}}`,
+		},
+		{
+			name:    "key owned by another extension",
+			cm:      newConfigMap(map[string]string{"a": "1"}, `{"logs":{"keys":["a"]}}`),
+			owner:   "metrics",
+			desired: map[string]string{"a": "2"},
+			wantErr: true,
+		},
+		{
+			name:    "unmanaged key",
+			cm:      newConfigMap(map[string]string{"url": "https://argocd"}, ""),
+			owner:   "metrics",
+			desired: map[string]string{"url": "https://evil"},
+			wantErr: true,
+		},
+		{
+			name:    "invalid annotation",
+			cm:      newConfigMap(nil, "{"),
+			owner:   "metrics",
+			desired: map[string]string{"a": "1"},
+			wantErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := SetOwnedKeys(tc.cm, tc.owner, tc.desired)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("SetOwnedKeys() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(tc.cm.Data, tc.wantData) {
+				t.Errorf("data = %v, want %v", tc.cm.Data, tc.wantData)
+			}
+			if managed := tc.cm.Annotations[managedAnnotation]; managed != tc.wantManaged {
+				t.Errorf("managed annotation = %s, want %s", managed, tc.wantManaged)
+			}
+		})
+	}
+}
+
+func TestRemoveOwner(t *testing.T) {
+	cm := newConfigMap(map[string]string{"url": "https://argocd", "a": "1", "b": "2"},
+		`{"logs":{"keys":["b"]},"metrics":{"keys":["a"]}}`)
+	if err := RemoveOwner(cm, "metrics"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"url": "https://argocd", "b": "2"}; !reflect.DeepEqual(cm.Data, want) {
+		t.Errorf("data = %v, want %v", cm.Data, want)
+	}
+	if managed, want := cm.Annotations[managedAnnotation], `{"logs":{"keys":["b"]}}`; managed != want {
+		t.Errorf("managed annotation = %s, want %s", managed, want)
+	}
+
+	// removing an owner without settings changes nothing
+	if err := RemoveOwner(cm, "metrics"); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveOwner(cm, "logs"); err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]string{"url": "https://argocd"}; !reflect.DeepEqual(cm.Data, want) {
+		t.Errorf("data = %v, want %v", cm.Data, want)
+	}
+	if _, ok := cm.Annotations[managedAnnotation]; ok {
+		t.Errorf("managed annotation is not removed")
+	}
+}
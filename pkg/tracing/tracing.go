@@ -0,0 +1,100 @@
+package tracing
+
+import (
+	"context"
+	"net/url"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	serviceName = "argocd-extensions"
+	tracerName  = "github.com/argoproj/argocd-extensions"
+)
+
+var (
+	// ExtensionNamespace is the namespace of the ArgoCDExtension
+	ExtensionNamespace = attribute.Key("extension.namespace")
+	// ExtensionName is the name of the ArgoCDExtension
+	ExtensionName = attribute.Key("extension.name")
+	// SourceType is the type of the extension source
+	SourceType = attribute.Key("extension.source.type")
+	// SourceHost is the host of the extension source URL
+	SourceHost = attribute.Key("extension.source.host")
+	// SourceRevision is the resolved revision of the extension source
+	SourceRevision = attribute.Key("extension.source.revision")
+)
+
+// Enabled returns true if an OTLP endpoint is configured with the standard OTEL_EXPORTER_OTLP_ENDPOINT or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT environment variables
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" || os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Init registers a global tracer provider exporting spans over OTLP/HTTP if tracing is Enabled. The exporter is
+// configured with the standard OTEL_EXPORTER_OTLP_* environment variables and the resource with OTEL_SERVICE_NAME
+// and OTEL_RESOURCE_ATTRIBUTES. The returned function flushes and stops the exporter.
+func Init(ctx context.Context) (func(context.Context) error, error) {
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceNameKey.String(serviceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	return provider.Shutdown, nil
+}
+
+// Start creates a span using the global tracer provider. Spans are dropped if tracing is not initialized.
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End records the error, if any, in the span status and ends the span
+func End(span trace.Span, err error) {
+	SetError(span, err)
+	span.End()
+}
+
+// SetError records the error, if any, in the span status
+func SetError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+// Host returns the host of the given URL, including scp-like Git URLs (e.g. git@github.com:org/repo.git), or an
+// empty string if the URL can't be parsed
+func Host(rawURL string) string {
+	if !strings.Contains(rawURL, "://") {
+		if i := strings.Index(rawURL, ":"); i > -1 {
+			host := rawURL[:i]
+			return host[strings.LastIndex(host, "@")+1:]
+		}
+	}
+	if parsed, err := url.Parse(rawURL); err == nil {
+		return parsed.Host
+	}
+	return ""
+}